@@ -26,6 +26,10 @@ func (a Alphabet) Len() int {
 // Index returns a constant-time mapping from ASCII to residue index in the
 // alphabet. This depends on all residues in the alphabet being ASCII
 // characters.
+//
+// Note that residues not in the alphabet map to 0, which is indistinguishable
+// from the first residue in the alphabet. Use StrictIndex to detect residues
+// that aren't in the alphabet.
 func (a Alphabet) Index() [256]int {
 	var index [256]int
 	for i, r := range a {
@@ -34,6 +38,28 @@ func (a Alphabet) Index() [256]int {
 	return index
 }
 
+// AlphabetIndex is a constant-time mapping from ASCII to residue index in an
+// alphabet, where residues that aren't in the alphabet map to -1.
+type AlphabetIndex [256]int
+
+// StrictIndex returns a mapping from ASCII to residue index in the alphabet,
+// like Index, except residues not in the alphabet map to -1.
+func (a Alphabet) StrictIndex() AlphabetIndex {
+	var index AlphabetIndex
+	for i := range index {
+		index[i] = -1
+	}
+	for i, r := range a {
+		index[r] = i
+	}
+	return index
+}
+
+// Contains returns true if and only if the residue is in the alphabet.
+func (index AlphabetIndex) Contains(r Residue) bool {
+	return index[r] >= 0
+}
+
 // Equals returns true if and only if a1 == a2.
 func (a1 Alphabet) Equals(a2 Alphabet) bool {
 	if len(a1) != len(a2) {
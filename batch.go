@@ -0,0 +1,275 @@
+package seq
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// BatchConfig describes how to align many pairs of sequences concurrently.
+type BatchConfig struct {
+	// The number of goroutines used to compute alignments. If this is not
+	// positive, then runtime.GOMAXPROCS(0) is used.
+	Workers int
+
+	// NewAligner is called once by each worker to create the aligner it
+	// uses for all of its alignments. This allows each worker to reuse its
+	// own dynamic programming buffers (see AlignConfig.AlignMem), since the
+	// aligner returned is never called concurrently.
+	NewAligner func() AlignFunc
+}
+
+// NewBatchConfig returns a batch configuration that aligns with the given
+// configuration. Each worker reuses a single AlignTable for all of its
+// alignments.
+func NewBatchConfig(conf AlignConfig) BatchConfig {
+	return BatchConfig{
+		NewAligner: func() AlignFunc {
+			table := NewAlignTable()
+			return func(A, B []Residue) Alignment {
+				return conf.AlignMem(A, B, table)
+			}
+		},
+	}
+}
+
+// check returns an error if the configuration can't be used to align.
+func (b BatchConfig) check() error {
+	if b.NewAligner == nil {
+		return fmt.Errorf("batch configuration has no NewAligner")
+	}
+	return nil
+}
+
+// AlignResult is a single alignment computed in a batch.
+type AlignResult struct {
+	// The position of the result in the batch. Results are always sent in
+	// order of Index, starting at 0.
+	Index int
+
+	// The indices of the sequences aligned as A and B. When aligning a query
+	// against many targets, I is the index of the target and J is -1.
+	I, J int
+
+	Alignment Alignment
+
+	// Err is set if the aligner failed (e.g., because a sequence has a
+	// residue that the substitution matrix can't score). In that case,
+	// Alignment is empty.
+	Err error
+}
+
+// batchJob is a pair of sequences to align.
+type batchJob struct {
+	index, i, j int
+	A, B        []Residue
+}
+
+// align runs the aligner given on the job. If the aligner panics (which
+// AlignConfig.AlignMem does when a residue can't be scored), then the panic
+// is recovered and returned as the result's error, so that one bad sequence
+// doesn't bring down the whole batch.
+func (job batchJob) align(align AlignFunc) (r AlignResult) {
+	r = AlignResult{Index: job.index, I: job.i, J: job.j}
+	defer func() {
+		if v := recover(); v != nil {
+			r.Alignment = Alignment{}
+			r.Err = fmt.Errorf("aligning sequences (%d, %d): %v",
+				job.i, job.j, v)
+		}
+	}()
+	r.Alignment = align(job.A, job.B)
+	return r
+}
+
+// AlignStream aligns a query against every target received on the channel
+// given, until it is closed. Each target is aligned as A and the query as B
+// (which is the convention used by NewSAMRecord and SearchConfig).
+//
+// Results are sent on the channel returned in the same order as the targets,
+// regardless of which worker finishes first, and the channel is closed once
+// every target has been aligned. If the context is canceled, then no more
+// alignments are started and the channel returned is closed soon after. (The
+// caller should check ctx.Err() to see whether the results are complete.)
+//
+// The caller must either receive every result or cancel the context, or else
+// goroutines will leak. If an alignment fails, then the error is reported in
+// the Err field of its result and the rest of the alignments carry on.
+//
+// An error is returned (and nothing is aligned) if the configuration has no
+// NewAligner.
+func (b BatchConfig) AlignStream(
+	ctx context.Context,
+	query []Residue,
+	targets <-chan Sequence,
+) (<-chan AlignResult, error) {
+	return b.run(ctx, func(send func(batchJob) bool) {
+		index := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case target, ok := <-targets:
+				if !ok {
+					return
+				}
+				if !send(batchJob{index, index, -1, target.Residues, query}) {
+					return
+				}
+				index++
+			}
+		}
+	})
+}
+
+// Align aligns a query against every target, in the same way as AlignStream,
+// and returns the alignments in the same order as the targets. If the
+// context is canceled before every alignment is finished, then the context's
+// error is returned. Otherwise, if any alignment fails, then the error of the
+// first one to fail is returned. As with AlignStream, an error is also
+// returned if the configuration has no NewAligner.
+func (b BatchConfig) Align(
+	ctx context.Context,
+	query []Residue,
+	targets []Sequence,
+) ([]Alignment, error) {
+	results, err := b.run(ctx, func(send func(batchJob) bool) {
+		for i, target := range targets {
+			if !send(batchJob{i, i, -1, target.Residues, query}) {
+				return
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	aligned := make([]Alignment, 0, len(targets))
+	var alignErr error
+	for r := range results {
+		if r.Err != nil && alignErr == nil {
+			alignErr = r.Err
+		}
+		aligned = append(aligned, r.Alignment)
+	}
+	if len(aligned) < len(targets) {
+		return nil, ctx.Err()
+	}
+	if alignErr != nil {
+		return nil, alignErr
+	}
+	return aligned, nil
+}
+
+// AlignAll aligns every pair of distinct sequences. The pair (i, j) with
+// i < j is aligned with seqs[i] as A and seqs[j] as B. Results are sent in
+// the order (0, 1), (0, 2), ..., (0, n-1), (1, 2), and so on. Otherwise,
+// AlignAll behaves in the same way as AlignStream.
+func (b BatchConfig) AlignAll(
+	ctx context.Context,
+	seqs []Sequence,
+) (<-chan AlignResult, error) {
+	return b.run(ctx, func(send func(batchJob) bool) {
+		index := 0
+		for i := range seqs {
+			for j := i + 1; j < len(seqs); j++ {
+				job := batchJob{index, i, j, seqs[i].Residues, seqs[j].Residues}
+				if !send(job) {
+					return
+				}
+				index++
+			}
+		}
+	})
+}
+
+// run distributes the jobs generated by produce over the workers, and sends
+// their results in order on the channel returned. produce should stop as
+// soon as send returns false, which happens when the context is canceled.
+// If the configuration is invalid, then an error is returned and produce is
+// never called.
+//
+// The number of jobs that are in progress or waiting to be sent in order is
+// bounded, so that one slow alignment can't cause the results of every
+// other job to pile up in memory.
+func (b BatchConfig) run(
+	ctx context.Context,
+	produce func(send func(batchJob) bool),
+) (<-chan AlignResult, error) {
+	if err := b.check(); err != nil {
+		return nil, err
+	}
+	workers := b.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	jobs := make(chan batchJob)
+	finished := make(chan AlignResult)
+	out := make(chan AlignResult)
+	tokens := make(chan struct{}, 4*workers)
+
+	go func() {
+		defer close(jobs)
+		produce(func(job batchJob) bool {
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				return false
+			}
+			select {
+			case jobs <- job:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+
+	wg := new(sync.WaitGroup)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			align := b.NewAligner()
+			for job := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				r := job.align(align)
+				select {
+				case finished <- r:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	// Put the results back in order. This keeps draining finished after the
+	// context is canceled so that the workers can always exit.
+	go func() {
+		defer close(out)
+		pending := make(map[int]AlignResult)
+		next := 0
+		for r := range finished {
+			pending[r.Index] = r
+			for {
+				r, ok := pending[next]
+				if !ok || ctx.Err() != nil {
+					break
+				}
+				delete(pending, next)
+				select {
+				case out <- r:
+				case <-ctx.Done():
+				}
+				<-tokens
+				next++
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,204 @@
+package seq
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func batchSeqs(rng *rand.Rand, n int) []Sequence {
+	const alphabet = "ARNDCQEGHILKMFPSTWYV"
+	base := randomResidues(rng, 150, alphabet)
+	seqs := make([]Sequence, n)
+	for i := range seqs {
+		// Vary the lengths so that workers finish out of order.
+		rs := mutate(rng, base[rng.Intn(50):], 20, alphabet)
+		seqs[i] = Sequence{Name: fmt.Sprintf("seq%d", i), Residues: rs}
+	}
+	return seqs
+}
+
+func TestBatchAlign(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	seqs := batchSeqs(rng, 40)
+	query := seqs[0].Residues
+	conf := NewAlignConfig(SubstBlosum62)
+	conf.Mode = AlignLocal
+
+	batch := NewBatchConfig(conf)
+	batch.Workers = 4
+	aligned, err := batch.Align(context.Background(), query, seqs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(aligned) != len(seqs) {
+		t.Fatalf("Expected %d alignments but got %d.", len(seqs), len(aligned))
+	}
+	for i, s := range seqs {
+		expected := conf.Align(s.Residues, query)
+		if resString(aligned[i].A) != resString(expected.A) ||
+			resString(aligned[i].B) != resString(expected.B) ||
+			aligned[i].Score != expected.Score {
+			t.Fatalf("Expected alignment %d to be\n%s\n%s\n(score %d) "+
+				"but got\n%s\n%s\n(score %d).", i,
+				resString(expected.A), resString(expected.B), expected.Score,
+				resString(aligned[i].A), resString(aligned[i].B),
+				aligned[i].Score)
+		}
+	}
+
+	targets := make(chan Sequence)
+	go func() {
+		for _, s := range seqs {
+			targets <- s
+		}
+		close(targets)
+	}()
+	results, err := batch.AlignStream(context.Background(), query, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := 0
+	for r := range results {
+		if r.Index != index || r.I != index || r.J != -1 {
+			t.Fatalf("Expected result %d for target %d but got result %d "+
+				"for (%d, %d).", index, index, r.Index, r.I, r.J)
+		}
+		if r.Alignment.Score != aligned[index].Score {
+			t.Fatalf("Expected score %d for target %d but got %d.",
+				aligned[index].Score, index, r.Alignment.Score)
+		}
+		index++
+	}
+	if index != len(seqs) {
+		t.Fatalf("Expected %d results but got %d.", len(seqs), index)
+	}
+}
+
+func TestBatchAlignAll(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	seqs := batchSeqs(rng, 12)
+	conf := NewAlignConfig(SubstBlosum62)
+
+	batch := NewBatchConfig(conf)
+	batch.Workers = 3
+	results, err := batch.AlignAll(context.Background(), seqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := 0
+	for i := range seqs {
+		for j := i + 1; j < len(seqs); j++ {
+			r, ok := <-results
+			if !ok {
+				t.Fatalf("Results ended before pair (%d, %d).", i, j)
+			}
+			if r.Index != index || r.I != i || r.J != j {
+				t.Fatalf("Expected result %d for (%d, %d) but got result %d "+
+					"for (%d, %d).", index, i, j, r.Index, r.I, r.J)
+			}
+			expected := conf.Align(seqs[i].Residues, seqs[j].Residues)
+			if r.Alignment.Score != expected.Score {
+				t.Fatalf("Expected score %d for (%d, %d) but got %d.",
+					expected.Score, i, j, r.Alignment.Score)
+			}
+			index++
+		}
+	}
+	if r, ok := <-results; ok {
+		t.Fatalf("Expected no more results but got result %d.", r.Index)
+	}
+}
+
+func TestBatchCancel(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	seqs := batchSeqs(rng, 30)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	batch := NewBatchConfig(NewAlignConfig(SubstBlosum62))
+	batch.Workers = 2
+	results, err := batch.AlignAll(ctx, seqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for r := range results {
+		if r.Index != count {
+			t.Fatalf("Expected result %d but got %d.", count, r.Index)
+		}
+		count++
+		if count == 10 {
+			cancel()
+		}
+	}
+	if total := len(seqs) * (len(seqs) - 1) / 2; count >= total {
+		t.Fatalf("Expected fewer than %d results after canceling, but got %d.",
+			total, count)
+	}
+
+	_, err = batch.Align(ctx, seqs[0].Residues, seqs)
+	if err != context.Canceled {
+		t.Fatalf("Expected error '%s' but got '%v'.", context.Canceled, err)
+	}
+}
+
+func TestBatchConfigCheck(t *testing.T) {
+	var batch BatchConfig
+	seqs := []Sequence{NewSequenceString("a", "ACD")}
+	if _, err := batch.Align(context.Background(), nil, seqs); err == nil {
+		t.Fatalf("Expected an error aligning with no NewAligner.")
+	}
+	if _, err := batch.AlignAll(context.Background(), seqs); err == nil {
+		t.Fatalf("Expected an error aligning with no NewAligner.")
+	}
+	targets := make(chan Sequence)
+	if _, err := batch.AlignStream(
+		context.Background(), nil, targets); err == nil {
+		t.Fatalf("Expected an error aligning with no NewAligner.")
+	}
+}
+
+func TestBatchAlignError(t *testing.T) {
+	ac := SubstMatrix{
+		NewAlphabet('A', 'C', '-'),
+		[][]int{{1, -1, -2}, {-1, 1, -2}, {-2, -2, -2}},
+	}
+	seqs := []Sequence{
+		NewSequenceString("a", "ACCA"),
+		NewSequenceString("b", "ACGA"),
+		NewSequenceString("c", "CCAA"),
+	}
+	batch := NewBatchConfig(NewAlignConfig(ac))
+	batch.Workers = 2
+
+	_, err := batch.Align(context.Background(), seqs[0].Residues, seqs)
+	if err == nil || !strings.Contains(err.Error(), "residue 'G'") {
+		t.Fatalf("Expected an error about residue 'G' but got '%v'.", err)
+	}
+
+	results, err := batch.AlignAll(context.Background(), seqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r := range results {
+		failed := r.I == 1 || r.J == 1
+		if failed && r.Err == nil {
+			t.Fatalf("Expected an error aligning (%d, %d).", r.I, r.J)
+		} else if !failed && r.Err != nil {
+			t.Fatalf("Unexpected error aligning (%d, %d): %s", r.I, r.J, r.Err)
+		}
+	}
+}
+
+func BenchmarkBatchAlign(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	seqs := batchSeqs(rng, 200)
+	batch := NewBatchConfig(NewAlignConfig(SubstBlosum62))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		batch.Align(context.Background(), seqs[0].Residues, seqs)
+	}
+}
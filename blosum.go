@@ -1,5 +1,9 @@
 package seq
 
+import (
+	"fmt"
+)
+
 // SubstMatrix corresponds to a substitution matrix and an alphabet that is
 // used in sequence alignment algorithms. The matrix given should be square,
 // with rows and columns equivalent to the length of the alphabet. (This means
@@ -10,6 +14,134 @@ type SubstMatrix struct {
 	Scores   [][]int
 }
 
+// Validate returns an error if the substitution matrix is malformed. A valid
+// matrix has a non-empty alphabet with no duplicate residues, one row of
+// scores for each residue in the alphabet, one score in each row for each
+// residue in the alphabet and is symmetric.
+func (m SubstMatrix) Validate() error {
+	if len(m.Alphabet) == 0 {
+		return fmt.Errorf("substitution matrix has an empty alphabet")
+	}
+	index := m.Alphabet.StrictIndex()
+	for i, r := range m.Alphabet {
+		if index[r] != i {
+			return fmt.Errorf("residue '%c' appears more than once in the "+
+				"substitution matrix alphabet '%s'", rune(r), m.Alphabet)
+		}
+	}
+	if len(m.Scores) != len(m.Alphabet) {
+		return fmt.Errorf("substitution matrix has %d rows but its "+
+			"alphabet '%s' has %d residues",
+			len(m.Scores), m.Alphabet, len(m.Alphabet))
+	}
+	for i, row := range m.Scores {
+		if len(row) != len(m.Alphabet) {
+			return fmt.Errorf("row '%c' of substitution matrix has %d "+
+				"scores but its alphabet '%s' has %d residues",
+				rune(m.Alphabet[i]), len(row), m.Alphabet, len(m.Alphabet))
+		}
+	}
+	for i := range m.Scores {
+		for j := 0; j < i; j++ {
+			if m.Scores[i][j] != m.Scores[j][i] {
+				return fmt.Errorf("substitution matrix is not symmetric: "+
+					"score of ('%c', '%c') is %d but score of ('%c', '%c') "+
+					"is %d", rune(m.Alphabet[i]), rune(m.Alphabet[j]),
+					m.Scores[i][j], rune(m.Alphabet[j]), rune(m.Alphabet[i]),
+					m.Scores[j][i])
+			}
+		}
+	}
+	return nil
+}
+
+// ScoreIndex returns a mapping from ASCII to a row (or column) of the
+// substitution matrix that can be used to score every residue in the
+// sequences given. Lower case residues that aren't in the alphabet of the
+// matrix are mapped to the row of their upper case equivalent. Other residues
+// that aren't in the alphabet are mapped to the row for 'X' (any amino acid)
+// or, if the alphabet has no 'X', the row for 'N' (any nucleotide). If the
+// alphabet has neither, then an error is returned naming the first residue
+// that cannot be scored and its position (both 1-indexed) among the
+// sequences given.
+//
+// Residues that don't appear in any of the sequences and aren't in the
+// alphabet map to -1.
+func (m SubstMatrix) ScoreIndex(seqs ...[]Residue) (AlphabetIndex, error) {
+	index := m.Alphabet.StrictIndex()
+	for k, rs := range seqs {
+		for i, r := range rs {
+			if index.Contains(r) {
+				continue
+			}
+			if index[r] = m.fallback(index, r); index[r] < 0 {
+				return index, fmt.Errorf("residue '%c' at position %d of "+
+					"sequence %d is not in the substitution matrix alphabet "+
+					"'%s' (which has no 'X' or 'N' to use instead)",
+					rune(r), i+1, k+1, m.Alphabet)
+			}
+		}
+	}
+	return index, nil
+}
+
+// fallback returns the row used by ScoreIndex for a residue that isn't in the
+// alphabet of the matrix, given the StrictIndex of the alphabet, or -1 if
+// there is none.
+func (m SubstMatrix) fallback(index AlphabetIndex, r Residue) int {
+	switch {
+	case index.Contains(residueUpper(r)):
+		return index[residueUpper(r)]
+	case index.Contains('X'):
+		return index['X']
+	case index.Contains('N'):
+		return index['N']
+	}
+	return -1
+}
+
+// mustScoreIndex is like ScoreIndex, except it panics with a message
+// prefixed by the name of the caller if a residue cannot be scored.
+func (m SubstMatrix) mustScoreIndex(
+	caller string,
+	seqs ...[]Residue,
+) AlphabetIndex {
+	index, err := m.ScoreIndex(seqs...)
+	if err != nil {
+		panic(fmt.Sprintf("%s: %s", caller, err))
+	}
+	return index
+}
+
+// pairIndex returns the score index for a pair of sequences to be aligned,
+// as computed by ScoreIndex, along with the score of aligning '-' with '-',
+// which is used as a linear gap penalty. An error is returned if a residue
+// cannot be scored or if the alphabet has no gap character.
+func (m SubstMatrix) pairIndex(A, B []Residue) (AlphabetIndex, int, error) {
+	index, err := m.ScoreIndex(A, B)
+	if err != nil {
+		return index, 0, err
+	}
+	if !index.Contains('-') {
+		return index, 0, fmt.Errorf("the substitution matrix alphabet '%s' "+
+			"has no gap character '-' to use as a gap penalty", m.Alphabet)
+	}
+	return index, m.Scores[index['-']][index['-']], nil
+}
+
+// mustPairIndex is like pairIndex, except it panics with a message prefixed
+// by the name of the caller if there is an error.
+func (m SubstMatrix) mustPairIndex(
+	caller string,
+	A, B []Residue,
+) (AlphabetIndex, int) {
+	index, gapPenalty, err := m.pairIndex(A, B)
+	if err != nil {
+		panic(fmt.Sprintf("%s: %s", caller, err))
+	}
+	return index, gapPenalty
+}
+
 var (
 	identity = [][]int{
 		{
@@ -0,0 +1,163 @@
+package seq
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubstMatrixValidate(t *testing.T) {
+	for name, subst := range SubstMatrices {
+		if err := subst.Validate(); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		subst SubstMatrix
+		err   string
+	}{
+		{SubstMatrix{}, "empty alphabet"},
+		{
+			SubstMatrix{NewAlphabet('A', 'A'), [][]int{{1, 0}, {0, 1}}},
+			"more than once",
+		},
+		{SubstMatrix{NewAlphabet('A', 'C'), [][]int{{1, 0}}}, "1 rows"},
+		{
+			SubstMatrix{NewAlphabet('A', 'C'), [][]int{{1, 0}, {0}}},
+			"row 'C'",
+		},
+		{
+			SubstMatrix{NewAlphabet('A', 'C'), [][]int{{1, 0}, {-1, 1}}},
+			"not symmetric",
+		},
+	}
+	for _, test := range tests {
+		err := test.subst.Validate()
+		if err == nil {
+			t.Fatalf("Expected an error validating %v.", test.subst)
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("Expected error containing '%s' but got '%s'.",
+				test.err, err)
+		}
+	}
+}
+
+func TestScoreIndex(t *testing.T) {
+	A, B := []Residue("ACDU"), []Residue("ACOD")
+	idx, err := SubstBlosum62.ScoreIndex(A, B)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := SubstBlosum62.Alphabet.StrictIndex()['X']
+	if idx['U'] != x || idx['O'] != x {
+		t.Fatalf("Expected 'U' and 'O' to map to 'X' (%d), but got %d and %d.",
+			x, idx['U'], idx['O'])
+	}
+	if idx['J'] != -1 {
+		t.Fatalf("Expected 'J' to map to -1 but got %d.", idx['J'])
+	}
+
+	idx, err = SubstDNA.ScoreIndex([]Residue("ACGT"), []Residue("acgRY"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dna := SubstDNA.Alphabet.StrictIndex()
+	if idx['a'] != dna['A'] || idx['t'] != -1 {
+		t.Fatalf("Expected 'a' to map to 'A' (%d) and 't' to -1, but got "+
+			"%d and %d.", dna['A'], idx['a'], idx['t'])
+	}
+	if idx['R'] != dna['N'] || idx['Y'] != dna['N'] {
+		t.Fatalf("Expected 'R' and 'Y' to map to 'N' (%d), but got %d "+
+			"and %d.", dna['N'], idx['R'], idx['Y'])
+	}
+
+	ac := SubstMatrix{NewAlphabet('A', 'C'), [][]int{{1, 0}, {0, 1}}}
+	_, err = ac.ScoreIndex([]Residue("ACCA"), []Residue("ACG"))
+	if err == nil {
+		t.Fatalf("Expected an error for 'G' in an alphabet without 'X' " +
+			"or 'N'.")
+	}
+	if !strings.Contains(err.Error(), "position 3 of sequence 2") {
+		t.Fatalf("Expected the error to locate the residue, but got '%s'.",
+			err)
+	}
+}
+
+func TestAlignUnknownResidues(t *testing.T) {
+	// Unknown residues should be scored exactly like 'X'.
+	A, B := []Residue("ACDUEFG"), []Residue("ACDEOFG")
+	AX, BX := []Residue("ACDXEFG"), []Residue("ACDEXFG")
+	if s1, s2 := NeedlemanWunsch(A, B, SubstBlosum62).Score,
+		NeedlemanWunsch(AX, BX, SubstBlosum62).Score; s1 != s2 {
+		t.Fatalf("Expected score %d but got %d.", s2, s1)
+	}
+	conf := NewAlignConfig(SubstBlosum62)
+	if s1, s2 := conf.Align(A, B).Score, conf.Align(AX, BX).Score; s1 != s2 {
+		t.Fatalf("Expected score %d but got %d.", s2, s1)
+	}
+
+	// Ambiguous nucleotides are scored as 'N'.
+	for _, subst := range []SubstMatrix{SubstDNA, SubstRNA} {
+		s1 := NeedlemanWunsch([]Residue("ACRGT"), []Residue("ACGYT"), subst)
+		s2 := NeedlemanWunsch([]Residue("ACNGT"), []Residue("ACGNT"), subst)
+		if s1.Score != s2.Score {
+			t.Fatalf("Expected score %d but got %d.", s2.Score, s1.Score)
+		}
+	}
+
+	testPanic := func(name, msg string, f func()) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				t.Fatalf("%s: expected a panic.", name)
+			}
+			if !strings.Contains(r.(string), msg) {
+				t.Fatalf("%s: expected panic containing '%s' but got '%s'.",
+					name, msg, r)
+			}
+		}()
+		f()
+	}
+	ac := SubstMatrix{
+		NewAlphabet('A', 'C', '-'),
+		[][]int{{1, -1, -2}, {-1, 1, -2}, {-2, -2, -2}},
+	}
+	nogap := SubstMatrix{NewAlphabet('A', 'C'), [][]int{{1, 0}, {0, 1}}}
+	A, B = []Residue("ACCA"), []Residue("ACGA")
+	testPanic("NeedlemanWunsch", "residue 'G'", func() {
+		NeedlemanWunsch(A, B, ac)
+	})
+	testPanic("SmithWaterman", "residue 'G'", func() {
+		SmithWaterman(A, B, ac)
+	})
+	testPanic("Hirschberg", "residue 'G'", func() {
+		Hirschberg(A, B, ac)
+	})
+	testPanic("NeedlemanWunsch", "no gap character", func() {
+		NeedlemanWunsch([]Residue("AC"), []Residue("CA"), nogap)
+	})
+
+	aligners := map[string]func(A, B []Residue) (Alignment, error){
+		"NeedlemanWunsch": ac.NeedlemanWunsch,
+		"SmithWaterman":   ac.SmithWaterman,
+		"Hirschberg":      ac.Hirschberg,
+	}
+	for name, align := range aligners {
+		if _, err := align(A, B); err == nil ||
+			!strings.Contains(err.Error(), "residue 'G'") {
+			t.Fatalf("%s: expected an error about 'G' but got %v.",
+				name, err)
+		}
+		aligned, err := align(A, A)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if aligned.Score != 4 {
+			t.Fatalf("%s: expected score 4 but got %d.", name, aligned.Score)
+		}
+	}
+	if _, err := nogap.NeedlemanWunsch(A, A); err == nil {
+		t.Fatalf("Expected an error for a matrix without a gap character.")
+	}
+}
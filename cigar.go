@@ -0,0 +1,208 @@
+package seq
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// CigarOp is a single operation in a CIGAR string, e.g., "10M".
+type CigarOp struct {
+	Op  byte
+	Len int
+}
+
+// Cigar is a compact representation of a pairwise alignment of a query
+// sequence to a reference sequence, as used in the SAM format.
+//
+// The operations used by this package are 'M' (aligned residues), '=' (equal
+// aligned residues), 'X' (unequal aligned residues), 'I' (a residue in the
+// query aligned to a gap), 'D' (a residue in the reference aligned to a gap)
+// and 'S' (a soft clipped residue in the query that isn't aligned).
+type Cigar []CigarOp
+
+// Cigar returns the CIGAR representation of the alignment, where A is the
+// reference sequence and B is the query sequence.
+//
+// queryLen should be the length of the full query sequence. Any residues in
+// the query sequence before BStart or at or after BEnd are soft clipped.
+// (This happens with local alignments.)
+//
+// When extended is true, aligned residues are described with '=' and 'X'
+// operations, where residues are compared case insensitively. Otherwise, the
+// 'M' operation is used.
+func (a Alignment) Cigar(queryLen int, extended bool) Cigar {
+	cigar := make(Cigar, 0, 10)
+	push := func(op byte) {
+		if n := len(cigar); n > 0 && cigar[n-1].Op == op {
+			cigar[n-1].Len++
+		} else {
+			cigar = append(cigar, CigarOp{op, 1})
+		}
+	}
+
+	if a.BStart > 0 {
+		cigar = append(cigar, CigarOp{'S', a.BStart})
+	}
+	for i := range a.A {
+		switch {
+		case a.A[i] == '-':
+			push('I')
+		case a.B[i] == '-':
+			push('D')
+		case !extended:
+			push('M')
+		case sameResidue(a.A[i], a.B[i]):
+			push('=')
+		default:
+			push('X')
+		}
+	}
+	if queryLen > a.BEnd {
+		cigar = append(cigar, CigarOp{'S', queryLen - a.BEnd})
+	}
+	return cigar
+}
+
+// ParseCigar parses a CIGAR string. All operations in the SAM specification
+// are recognized ("MIDNSHP=X"). The special CIGAR string "*" corresponds to
+// an empty (nil) Cigar.
+func ParseCigar(s string) (Cigar, error) {
+	if s == "*" {
+		return nil, nil
+	}
+	cigar := make(Cigar, 0, 10)
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			continue
+		}
+		if !bytes.ContainsRune([]byte("MIDNSHP=X"), rune(s[i])) {
+			return nil, fmt.Errorf("invalid operation '%c' in CIGAR string "+
+				"'%s'", s[i], s)
+		}
+		n, err := strconv.Atoi(s[start:i])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid length '%s' in CIGAR string "+
+				"'%s'", s[start:i], s)
+		}
+		cigar = append(cigar, CigarOp{s[i], n})
+		start = i + 1
+	}
+	if start != len(s) || len(cigar) == 0 {
+		return nil, fmt.Errorf("invalid CIGAR string '%s'", s)
+	}
+	return cigar, nil
+}
+
+// String returns the CIGAR string, e.g., "3S10M2I5M". An empty Cigar is
+// represented by "*".
+func (c Cigar) String() string {
+	if len(c) == 0 {
+		return "*"
+	}
+	buf := new(bytes.Buffer)
+	for _, op := range c {
+		fmt.Fprintf(buf, "%d%c", op.Len, op.Op)
+	}
+	return buf.String()
+}
+
+// RefLen returns the number of reference residues covered by the CIGAR.
+func (c Cigar) RefLen() int {
+	n := 0
+	for _, op := range c {
+		switch op.Op {
+		case 'M', 'D', 'N', '=', 'X':
+			n += op.Len
+		}
+	}
+	return n
+}
+
+// QueryLen returns the number of query residues described by the CIGAR,
+// including soft clipped residues.
+func (c Cigar) QueryLen() int {
+	n := 0
+	for _, op := range c {
+		switch op.Op {
+		case 'M', 'I', 'S', '=', 'X':
+			n += op.Len
+		}
+	}
+	return n
+}
+
+// Alignment reconstructs the pairwise alignment described by the CIGAR.
+// ref is the reference sequence, query is the full query sequence (including
+// any soft clipped residues) and refStart is the 0-indexed position in the
+// reference where the alignment starts.
+//
+// Hard clips and padding are ignored. Skipped reference regions ('N') are
+// not supported, since they cannot be represented in an Alignment.
+//
+// The score of the alignment returned is always zero.
+func (c Cigar) Alignment(ref, query []Residue, refStart int) (Alignment, error) {
+	if c.QueryLen() != len(query) {
+		return Alignment{}, fmt.Errorf("CIGAR '%s' describes a query of "+
+			"length %d, but the query has length %d",
+			c, c.QueryLen(), len(query))
+	}
+	if refStart < 0 || refStart+c.RefLen() > len(ref) {
+		return Alignment{}, fmt.Errorf("CIGAR '%s' starting at %d runs "+
+			"past the end of the reference (length %d)",
+			c, refStart, len(ref))
+	}
+
+	aligned := newAlignment(len(query) + c.RefLen())
+	i, j := refStart, 0
+	aligned.AStart, aligned.AEnd = refStart, refStart
+	for k, op := range c {
+		switch op.Op {
+		case 'S':
+			if !onlyHardClips(c[:k]) && !onlyHardClips(c[k+1:]) {
+				return Alignment{}, fmt.Errorf("soft clips may only appear "+
+					"at the ends of a CIGAR string, but got '%s'", c)
+			}
+			if aligned.Len() == 0 {
+				aligned.BStart = j + op.Len
+			}
+			j += op.Len
+		case 'M', '=', 'X':
+			aligned.A = append(aligned.A, ref[i:i+op.Len]...)
+			aligned.B = append(aligned.B, query[j:j+op.Len]...)
+			i, j = i+op.Len, j+op.Len
+		case 'I':
+			for n := 0; n < op.Len; n++ {
+				aligned.A = append(aligned.A, '-')
+			}
+			aligned.B = append(aligned.B, query[j:j+op.Len]...)
+			j += op.Len
+		case 'D':
+			aligned.A = append(aligned.A, ref[i:i+op.Len]...)
+			for n := 0; n < op.Len; n++ {
+				aligned.B = append(aligned.B, '-')
+			}
+			i += op.Len
+		case 'N':
+			return Alignment{}, fmt.Errorf("skipped regions ('N') are not "+
+				"supported in CIGAR '%s'", c)
+		}
+		if op.Op != 'S' && op.Op != 'H' && op.Op != 'P' {
+			aligned.AEnd, aligned.BEnd = i, j
+		}
+	}
+	if aligned.Len() == 0 {
+		aligned.BEnd = aligned.BStart
+	}
+	return aligned, nil
+}
+
+func onlyHardClips(c Cigar) bool {
+	for _, op := range c {
+		if op.Op != 'H' {
+			return false
+		}
+	}
+	return true
+}
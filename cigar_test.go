@@ -0,0 +1,133 @@
+package seq
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCigar(t *testing.T) {
+	conf := NewAlignConfig(SubstBlosum62)
+	tests := []struct {
+		ref, query string
+		aligned    Alignment
+		cigar      string
+		extended   string
+	}{
+		{
+			"TTTACGTAAA", "GGACGTGG",
+			SmithWaterman(stringToSeq("TTTACGTAAA"), stringToSeq("GGACGTGG"),
+				SubstDNA),
+			"2S4M2S", "2S4=2S",
+		},
+		{
+			"HIKLMN", "HIKWLMN",
+			conf.Align(stringToSeq("HIKLMN"), stringToSeq("HIKWLMN")),
+			"3M1I3M", "3=1I3=",
+		},
+		{
+			"GHIKLMNPQR", "GAAAHIKLMN",
+			NeedlemanWunsch(stringToSeq("GHIKLMNPQR"),
+				stringToSeq("GAAAHIKLMN"), SubstBlosum62),
+			"1M3I6M3D", "1=3I6=3D",
+		},
+	}
+	for _, test := range tests {
+		query := stringToSeq(test.query)
+		for _, c := range []struct {
+			extended bool
+			answer   string
+		}{{false, test.cigar}, {true, test.extended}} {
+			cigar := test.aligned.Cigar(len(query), c.extended)
+			if cigar.String() != c.answer {
+				t.Fatalf("Expected CIGAR '%s' but got '%s'.",
+					c.answer, cigar)
+			}
+
+			parsed, err := ParseCigar(c.answer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			aligned, err := parsed.Alignment(stringToSeq(test.ref), query,
+				test.aligned.AStart)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resString(aligned.A) != resString(test.aligned.A) ||
+				resString(aligned.B) != resString(test.aligned.B) ||
+				aligned.AStart != test.aligned.AStart ||
+				aligned.AEnd != test.aligned.AEnd ||
+				aligned.BStart != test.aligned.BStart ||
+				aligned.BEnd != test.aligned.BEnd {
+				t.Fatalf("CIGAR '%s' resulted in\n%s\n%s\nbut expected\n%s\n%s",
+					c.answer, aligned.A, aligned.B,
+					test.aligned.A, test.aligned.B)
+			}
+		}
+	}
+}
+
+func TestParseCigarErrors(t *testing.T) {
+	for _, s := range []string{"", "M", "10", "3M0I", "3Q", "2M2S3M"} {
+		cigar, err := ParseCigar(s)
+		if err == nil {
+			_, err = cigar.Alignment(stringToSeq("AAAAAAAAAA"),
+				stringToSeq("AAAAAAA"), 0)
+		}
+		if err == nil {
+			t.Fatalf("Expected an error for CIGAR '%s'.", s)
+		}
+		if msg := err.Error(); strings.HasSuffix(msg, ".") ||
+			strings.ToLower(msg[:1]) != msg[:1] {
+			t.Fatalf("Expected a lower case error with no trailing period "+
+				"but got '%s'.", msg)
+		}
+	}
+}
+
+func TestSAMRecordCase(t *testing.T) {
+	ref := NewSequenceString("ref", "ACGTACGT")
+	query := NewSequenceString("query", "acgtACGA")
+	aligned := Alignment{
+		A: ref.Residues, B: query.Residues,
+		AStart: 0, AEnd: 8, BStart: 0, BEnd: 8,
+	}
+	rec := NewSAMRecord(ref, query, aligned, true)
+	if c := rec.Cigar.String(); c != "7=1X" {
+		t.Fatalf("Expected CIGAR '7=1X' but got '%s'.", c)
+	}
+	if rec.Tags[1] != "NM:i:1" {
+		t.Fatalf("Expected tag 'NM:i:1' but got '%s'.", rec.Tags[1])
+	}
+}
+
+func TestSAMWriter(t *testing.T) {
+	ref := NewSequenceString("chr1 test reference", "TTTACGTAAA")
+	query := NewSequenceString("read1", "GGACGTGG")
+	unmapped := NewSequenceString("read2", "CCCC")
+	aligned := SmithWaterman(ref.Residues, query.Residues, SubstDNA)
+
+	buf := new(bytes.Buffer)
+	w := NewSAMWriter(buf)
+	if err := w.WriteHeader([]Sequence{ref}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(NewSAMRecord(ref, query, aligned, false)); err != nil {
+		t.Fatal(err)
+	}
+	rec := NewSAMRecord(ref, unmapped, Alignment{}, false)
+	if err := w.Write(rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	answer := "@HD\tVN:1.6\tSO:unsorted\n" +
+		"@SQ\tSN:chr1\tLN:10\n" +
+		"read1\t0\tchr1\t4\t255\t2S4M2S\t*\t0\t0\tGGACGTGG\t*\tAS:i:8\tNM:i:0\n" +
+		"read2\t4\t*\t0\t0\t*\t*\t0\t0\tCCCC\t*\n"
+	if buf.String() != answer {
+		t.Fatalf("Expected\n%s\nbut got\n%s", answer, buf.String())
+	}
+}
@@ -0,0 +1,191 @@
+package seq
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FastaReader reads sequences from a FASTA formatted input one at a time.
+//
+// Records may span any number of lines. Blank lines and lines starting with
+// a ';' (comments) are ignored, and both LF and CRLF line endings are
+// accepted. Whitespace inside a sequence line is dropped.
+type FastaReader struct {
+	lines *lineReader
+
+	// The header of the next record, if one has already been read.
+	header     string
+	headerLine int
+	hasHeader  bool
+	done       bool
+
+	// The line of the header of the last sequence returned by Read.
+	lastLine int
+}
+
+// NewFastaReader creates a new FASTA reader that reads from r.
+func NewFastaReader(r io.Reader) *FastaReader {
+	return &FastaReader{lines: newLineReader(r)}
+}
+
+// Read returns the next sequence in the input. When there are no more
+// sequences, io.EOF is returned. A record with a header but no residues is
+// read as a sequence with no residues.
+//
+// Any parse error returned includes the line number at which the error
+// occurred.
+func (r *FastaReader) Read() (Sequence, error) {
+	if !r.hasHeader {
+		if err := r.nextHeader(); err != nil {
+			return Sequence{}, err
+		}
+	}
+
+	s := Sequence{
+		Name:     r.header,
+		Residues: make([]Residue, 0, 64),
+	}
+	r.hasHeader = false
+	r.lastLine = r.headerLine
+	for !r.done {
+		line, err := r.lines.next()
+		if err == io.EOF {
+			r.done = true
+			break
+		} else if err != nil {
+			return Sequence{}, err
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == ';' {
+			continue
+		}
+		if line[0] == '>' {
+			r.header = strings.TrimSpace(line[1:])
+			r.headerLine = r.lines.line
+			r.hasHeader = true
+			break
+		}
+		for i := 0; i < len(line); i++ {
+			b := line[i]
+			if isSpace(b) {
+				continue
+			}
+			if b < '!' || b > '~' {
+				return Sequence{}, r.lines.errorf("invalid residue byte %q in "+
+					"sequence '%s'", b, s.Name)
+			}
+			s.Residues = append(s.Residues, Residue(b))
+		}
+	}
+	return s, nil
+}
+
+// ReadAll reads all remaining sequences from the input.
+func (r *FastaReader) ReadAll() ([]Sequence, error) {
+	seqs := make([]Sequence, 0, 10)
+	for {
+		s, err := r.Read()
+		if err == io.EOF {
+			return seqs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		seqs = append(seqs, s)
+	}
+}
+
+// nextHeader skips over leading blank lines and comments until the first
+// header is found.
+func (r *FastaReader) nextHeader() error {
+	for !r.done {
+		line, err := r.lines.next()
+		if err == io.EOF {
+			r.done = true
+			break
+		} else if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == ';' {
+			continue
+		}
+		if line[0] != '>' {
+			return r.lines.errorf("expected a FASTA header starting with '>' "+
+				"but got '%s'", line)
+		}
+		r.header = strings.TrimSpace(line[1:])
+		r.headerLine = r.lines.line
+		r.hasHeader = true
+		return nil
+	}
+	return io.EOF
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n' || b == '\v' ||
+		b == '\f'
+}
+
+// FastaWriter writes sequences in FASTA format.
+type FastaWriter struct {
+	w *bufio.Writer
+
+	// Columns is the maximum number of residues written on a single line.
+	// When Columns is zero or negative, each sequence is written on a
+	// single line.
+	Columns int
+}
+
+// NewFastaWriter creates a new FASTA writer that writes to w. Sequences are
+// wrapped at 60 residues per line by default.
+func NewFastaWriter(w io.Writer) *FastaWriter {
+	return &FastaWriter{
+		w:       bufio.NewWriter(w),
+		Columns: 60,
+	}
+}
+
+// Write writes a single sequence. Output is buffered, so Flush must be
+// called when writing is finished.
+func (w *FastaWriter) Write(s Sequence) error {
+	if _, err := fmt.Fprintf(w.w, ">%s\n", s.Name); err != nil {
+		return err
+	}
+	residues := s.Bytes()
+	if w.Columns <= 0 {
+		if _, err := w.w.Write(residues); err != nil {
+			return err
+		}
+		return w.w.WriteByte('\n')
+	}
+	for start := 0; start < len(residues); start += w.Columns {
+		end := start + w.Columns
+		if end > len(residues) {
+			end = len(residues)
+		}
+		if _, err := w.w.Write(residues[start:end]); err != nil {
+			return err
+		}
+		if err := w.w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteAll writes all of the given sequences and flushes the output.
+func (w *FastaWriter) WriteAll(seqs []Sequence) error {
+	for _, s := range seqs {
+		if err := w.Write(s); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *FastaWriter) Flush() error {
+	return w.w.Flush()
+}
@@ -0,0 +1,134 @@
+package seq
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFastaReader(t *testing.T) {
+	input := "; a comment\r\n" +
+		"\r\n" +
+		">seq1 first sequence\r\n" +
+		"ABCD\r\n" +
+		"EFG\r\n" +
+		"\r\n" +
+		">seq2\n" +
+		"; comment inside a record\n" +
+		"AB CD\n" +
+		"EF\n" +
+		">seq3\n" +
+		"XYZ"
+	answers := []Sequence{
+		NewSequenceString("seq1 first sequence", "ABCDEFG"),
+		NewSequenceString("seq2", "ABCDEF"),
+		NewSequenceString("seq3", "XYZ"),
+	}
+
+	seqs, err := NewFastaReader(strings.NewReader(input)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seqs) != len(answers) {
+		t.Fatalf("Expected %d sequences but got %d.", len(answers), len(seqs))
+	}
+	for i := range seqs {
+		if seqs[i].Name != answers[i].Name {
+			t.Fatalf("Expected name '%s' but got '%s'.",
+				answers[i].Name, seqs[i].Name)
+		}
+		testEqualSeq(t, seqs[i].Residues, answers[i].Residues)
+	}
+}
+
+func TestFastaReaderErrors(t *testing.T) {
+	tests := []struct {
+		input, line string
+	}{
+		{"ABCD\n>seq1\nABCD\n", "line 1:"},
+		{">seq1\nABCD\n\n>seq2\n>seq3\nAB\x7fCD\n", "line 6:"},
+		{">seq1\nAB\x01CD\n", "line 2:"},
+	}
+	for _, test := range tests {
+		_, err := NewFastaReader(strings.NewReader(test.input)).ReadAll()
+		if err == nil {
+			t.Fatalf("Expected an error reading\n%s", test.input)
+		}
+		if !strings.HasPrefix(err.Error(), test.line) {
+			t.Fatalf("Expected error starting with '%s' but got '%s'.",
+				test.line, err)
+		}
+	}
+}
+
+func TestFastaWriter(t *testing.T) {
+	seqs := []Sequence{
+		NewSequenceString("seq1", "ABCDEFG"),
+		NewSequenceString("seq2", "ABC"),
+	}
+	tests := []struct {
+		columns int
+		answer  string
+	}{
+		{0, ">seq1\nABCDEFG\n>seq2\nABC\n"},
+		{3, ">seq1\nABC\nDEF\nG\n>seq2\nABC\n"},
+	}
+	for _, test := range tests {
+		buf := new(bytes.Buffer)
+		w := NewFastaWriter(buf)
+		w.Columns = test.columns
+		if err := w.WriteAll(seqs); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.answer {
+			t.Fatalf("Expected\n%s\nbut got\n%s", test.answer, buf.String())
+		}
+	}
+}
+
+func TestFastaRoundTrip(t *testing.T) {
+	seqs := []Sequence{
+		NewSequenceString("empty", ""),
+		NewSequenceString("seq1", "ABCDEFG"),
+		NewSequenceString("", ""),
+	}
+	for _, columns := range []int{0, 3} {
+		buf := new(bytes.Buffer)
+		w := NewFastaWriter(buf)
+		w.Columns = columns
+		if err := w.WriteAll(seqs); err != nil {
+			t.Fatal(err)
+		}
+		computed, err := NewFastaReader(buf).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(computed) != len(seqs) {
+			t.Fatalf("Expected %d sequences but got %d.",
+				len(seqs), len(computed))
+		}
+		for i := range seqs {
+			if computed[i].Name != seqs[i].Name {
+				t.Fatalf("Expected name '%s' but got '%s'.",
+					seqs[i].Name, computed[i].Name)
+			}
+			testEqualSeq(t, computed[i].Residues, seqs[i].Residues)
+		}
+	}
+}
+
+func ExampleFastaReader() {
+	input := ">seq1\nGHIK\nLMN\n>seq2\nGAAAHIKLMN\n"
+	r := NewFastaReader(strings.NewReader(input))
+	seqs, err := r.ReadAll()
+	if err != nil {
+		panic(err)
+	}
+	for _, s := range seqs {
+		fmt.Printf("%s: %s\n", s.Name, s.Residues)
+	}
+	// Output:
+	// seq1: GHIKLMN
+	// seq2: GAAAHIKLMN
+}
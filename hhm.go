@@ -0,0 +1,472 @@
+package seq
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// HHM represents a profile HMM read from an HHsuite .hhm file, along with
+// its header, secondary structure annotations and (optionally) the multiple
+// sequence alignment embedded in the file.
+//
+// Node 0 of the HMM is the begin state, whose transitions come from the line
+// immediately following the transition header. Nodes 1 through LENG
+// correspond to the match states. Since .hhm files do not include insertion
+// emissions, the NULL model is used for the insertion emissions of every
+// node.
+type HHM struct {
+	Meta      HHMMeta
+	Secondary HHMSecondary
+
+	// The sequences in the SEQ block that aren't secondary structure
+	// annotations. If there is no SEQ block, the MSA is empty.
+	MSA MSA
+
+	HMM *HMM
+}
+
+// HHMMeta contains the header fields of an .hhm file.
+type HHMMeta struct {
+	// The first line of the file, e.g., "HHsearch 1.5".
+	Format string
+
+	Name string
+	Fam  string
+	File string
+	Com  string
+	Date string
+
+	// The number of match states and the number of columns in the multiple
+	// alignment used to build the HMM.
+	Leng, LengColumns int
+
+	Filt string
+	Neff float64
+
+	// Any header lines that aren't recognized, in the order in which they
+	// were read.
+	Extra []string
+}
+
+// HHMSecondary contains the secondary structure sequences that may be found
+// in the SEQ block of an .hhm file. Sequences that are not present are null
+// (see Sequence.IsNull).
+type HHMSecondary struct {
+	SSdssp    Sequence
+	SAdssp    Sequence
+	SSpred    Sequence
+	SSconf    Sequence
+	Consensus Sequence
+}
+
+// HHsuite stores probabilities as integers equal to -1000 * log2(p).
+// Multiplying by hhmScale converts these to the natural log-probabilities
+// used by Prob.
+const hhmScale = math.Ln2 / 1000.0
+
+// ReadHHM reads a single profile HMM in HHsuite .hhm format.
+func ReadHHM(r io.Reader) (*HHM, error) {
+	lines := newLineReader(r)
+	next := func() (string, error) {
+		line, err := lines.next()
+		if err == io.EOF {
+			return "", lines.errorf("unexpected end of HHM file")
+		}
+		return line, err
+	}
+
+	line, err := lines.nextNonBlank()
+	if err == io.EOF {
+		return nil, lines.errorf("unexpected end of HHM file")
+	} else if err != nil {
+		return nil, err
+	}
+	hhm := &HHM{Meta: HHMMeta{Format: line}, MSA: NewMSA()}
+
+	// Read the header, up to and including the HMM line.
+	var alphabet Alphabet
+	var nullFields []string
+	for alphabet == nil {
+		if line, err = next(); err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		tag := fields[0]
+		value := strings.TrimSpace(strings.TrimPrefix(line, tag))
+
+		switch tag {
+		case "NAME":
+			hhm.Meta.Name = value
+		case "FAM":
+			hhm.Meta.Fam = value
+		case "FILE":
+			hhm.Meta.File = value
+		case "COM":
+			hhm.Meta.Com = value
+		case "DATE":
+			hhm.Meta.Date = value
+		case "LENG":
+			_, err := fmt.Sscanf(value,
+				"%d match states, %d columns in multiple alignment",
+				&hhm.Meta.Leng, &hhm.Meta.LengColumns)
+			if err != nil {
+				return nil, lines.errorf("could not parse LENG '%s': %s",
+					value, err)
+			}
+		case "FILT":
+			hhm.Meta.Filt = value
+		case "NEFF":
+			hhm.Meta.Neff, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, lines.errorf("could not parse NEFF '%s': %s",
+					value, err)
+			}
+		case "SEQ":
+			if err := readHHMSeqs(lines, hhm); err != nil {
+				return nil, err
+			}
+		case "NULL":
+			nullFields = fields[1:]
+		case "HMM":
+			alphabet = make(Alphabet, len(fields)-1)
+			for i, f := range fields[1:] {
+				if len(f) != 1 {
+					return nil, lines.errorf("invalid residue '%s' in "+
+						"alphabet", f)
+				}
+				alphabet[i] = Residue(f[0])
+			}
+		default:
+			hhm.Meta.Extra = append(hhm.Meta.Extra, line)
+		}
+	}
+	if nullFields == nil {
+		return nil, lines.errorf("no NULL line found before HMM line")
+	}
+	null, err := parseHHMEmissions(alphabet, nullFields)
+	if err != nil {
+		return nil, lines.errorf("%s", err)
+	}
+
+	// The transition header line is ignored.
+	if line, err = next(); err != nil {
+		return nil, err
+	}
+	if !strings.Contains(line, "M->M") {
+		return nil, lines.errorf("expected transition header but got '%s'",
+			line)
+	}
+
+	nodes := make([]HMMNode, 0, hhm.Meta.Leng+1)
+	begin := HMMNode{NodeNum: 0, InsEmit: null, MatEmit: NewEProbs(alphabet)}
+	if line, err = next(); err != nil {
+		return nil, err
+	}
+	if err := parseHHMTransitions(line, &begin); err != nil {
+		return nil, lines.errorf("%s", err)
+	}
+	nodes = append(nodes, begin)
+
+	for {
+		if line, err = lines.nextNonBlank(); err == io.EOF {
+			return nil, lines.errorf("unexpected end of HHM file")
+		} else if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(line) == "//" {
+			break
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != len(alphabet)+3 {
+			return nil, lines.errorf("expected %d fields in match emission "+
+				"line but got %d", len(alphabet)+3, len(fields))
+		}
+		if len(fields[0]) != 1 {
+			return nil, lines.errorf("invalid residue '%s'", fields[0])
+		}
+		node := HMMNode{Residue: Residue(fields[0][0]), InsEmit: null}
+		if node.NodeNum, err = strconv.Atoi(fields[1]); err != nil {
+			return nil, lines.errorf("could not parse node number '%s': %s",
+				fields[1], err)
+		}
+		if node.NodeNum != len(nodes) {
+			return nil, lines.errorf("expected node %d but got node %d",
+				len(nodes), node.NodeNum)
+		}
+		node.MatEmit, err = parseHHMEmissions(alphabet,
+			fields[2:2+len(alphabet)])
+		if err != nil {
+			return nil, lines.errorf("%s", err)
+		}
+
+		if line, err = next(); err != nil {
+			return nil, err
+		}
+		if err := parseHHMTransitions(line, &node); err != nil {
+			return nil, lines.errorf("%s", err)
+		}
+		nodes = append(nodes, node)
+	}
+	if hhm.Meta.Leng > 0 && len(nodes)-1 != hhm.Meta.Leng {
+		return nil, lines.errorf("LENG is %d but the model has %d nodes",
+			hhm.Meta.Leng, len(nodes)-1)
+	}
+	hhm.HMM = NewHMM(nodes, alphabet, null)
+	return hhm, nil
+}
+
+// readHHMSeqs reads the sequences in a SEQ block, up to and including the
+// terminating '#' line. The sequences are in A3M format.
+func readHHMSeqs(lines *lineReader, hhm *HHM) error {
+	var seqs []Sequence
+	var cur *Sequence
+	for {
+		line, err := lines.next()
+		if err == io.EOF {
+			return lines.errorf("unexpected end of SEQ block")
+		} else if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == "#" {
+			break
+		}
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] == '>' {
+			seqs = append(seqs, Sequence{Name: strings.TrimSpace(line[1:])})
+			cur = &seqs[len(seqs)-1]
+			continue
+		}
+		if cur == nil {
+			return lines.errorf("expected a FASTA header in SEQ block but "+
+				"got '%s'", line)
+		}
+		for i := 0; i < len(line); i++ {
+			cur.Residues = append(cur.Residues, Residue(line[i]))
+		}
+	}
+
+	for _, s := range seqs {
+		switch {
+		case strings.HasPrefix(s.Name, "ss_dssp"):
+			hhm.Secondary.SSdssp = s
+		case strings.HasPrefix(s.Name, "sa_dssp"):
+			hhm.Secondary.SAdssp = s
+		case strings.HasPrefix(s.Name, "ss_pred"):
+			hhm.Secondary.SSpred = s
+		case strings.HasPrefix(s.Name, "ss_conf"):
+			hhm.Secondary.SSconf = s
+		case strings.HasPrefix(s.Name, "Consensus"):
+			hhm.Secondary.Consensus = s
+		default:
+			hhm.MSA.Add(s)
+		}
+	}
+	return nil
+}
+
+func parseHHMEmissions(alphabet Alphabet, fields []string) (EProbs, error) {
+	if len(fields) != len(alphabet) {
+		return EProbs{}, fmt.Errorf("expected %d emission probabilities but "+
+			"got %d", len(alphabet), len(fields))
+	}
+	eprobs := NewEProbs(alphabet)
+	for i, f := range fields {
+		p, err := newHHMProb(f)
+		if err != nil {
+			return EProbs{}, err
+		}
+		eprobs.Set(alphabet[i], p)
+	}
+	return eprobs, nil
+}
+
+func parseHHMTransitions(line string, node *HMMNode) error {
+	fields := strings.Fields(line)
+	if len(fields) != 10 {
+		return fmt.Errorf("expected 7 transition probabilities and 3 Neff "+
+			"values but got %d fields", len(fields))
+	}
+	var probs [7]Prob
+	for i := range probs {
+		var err error
+		if probs[i], err = newHHMProb(fields[i]); err != nil {
+			return err
+		}
+	}
+	node.Transitions = TProbs{
+		MM: probs[0], MI: probs[1], MD: probs[2],
+		IM: probs[3], II: probs[4],
+		DM: probs[5], DD: probs[6],
+	}
+
+	var neffs [3]Prob
+	for i := range neffs {
+		var err error
+		if neffs[i], err = NewProb(fields[7+i]); err != nil {
+			return err
+		}
+		if !neffs[i].IsMin() {
+			neffs[i] /= 1000.0
+		}
+	}
+	node.NeffM, node.NeffI, node.NeffD = neffs[0], neffs[1], neffs[2]
+	return nil
+}
+
+// newHHMProb converts a scaled integer log-probability (-1000 * log2(p)) to a
+// Prob. The special value "*" corresponds to the minimum probability.
+func newHHMProb(fstr string) (Prob, error) {
+	if fstr == "*" {
+		return MinProb, nil
+	}
+	n, err := strconv.Atoi(fstr)
+	if err != nil {
+		return invalidProb,
+			fmt.Errorf("Could not convert '%s' to a scaled log probability: %s",
+				fstr, err)
+	}
+	return Prob(float64(n) * hhmScale), nil
+}
+
+// formatHHMProb is the inverse of newHHMProb.
+func formatHHMProb(p Prob) string {
+	if p.IsMin() {
+		return "*"
+	}
+	return strconv.Itoa(int(math.Floor(float64(p)/hhmScale + 0.5)))
+}
+
+func formatHHMNeff(p Prob) string {
+	if p.IsMin() {
+		return "*"
+	}
+	return strconv.Itoa(int(math.Floor(float64(p)*1000.0 + 0.5)))
+}
+
+// WriteHHM writes the given profile HMM in HHsuite .hhm format. The embedded
+// MSA (if any) is written in A3M format.
+func WriteHHM(w io.Writer, hhm *HHM) error {
+	buf := bufio.NewWriter(w)
+	pf := func(format string, v ...interface{}) {
+		fmt.Fprintf(buf, format, v...)
+	}
+	nodes := hhm.HMM.Nodes
+	alphabet := hhm.HMM.Alphabet
+	if len(nodes) == 0 {
+		return fmt.Errorf("cannot write HMM '%s' with no nodes", hhm.Meta.Name)
+	}
+
+	meta := hhm.Meta
+	format := meta.Format
+	if len(format) == 0 {
+		format = "HHsearch 1.5"
+	}
+	cols := meta.LengColumns
+	if cols == 0 {
+		cols = len(nodes) - 1
+	}
+	pf("%s\n", format)
+	pf("NAME  %s\n", meta.Name)
+	if len(meta.Fam) > 0 {
+		pf("FAM   %s\n", meta.Fam)
+	}
+	if len(meta.File) > 0 {
+		pf("FILE  %s\n", meta.File)
+	}
+	if len(meta.Com) > 0 {
+		pf("COM   %s\n", meta.Com)
+	}
+	if len(meta.Date) > 0 {
+		pf("DATE  %s\n", meta.Date)
+	}
+	pf("LENG  %d match states, %d columns in multiple alignment\n",
+		len(nodes)-1, cols)
+	if len(meta.Filt) > 0 {
+		pf("FILT  %s\n", meta.Filt)
+	}
+	pf("NEFF  %0.1f\n", meta.Neff)
+	for _, line := range meta.Extra {
+		pf("%s\n", line)
+	}
+
+	sec := hhm.Secondary
+	secs := []Sequence{
+		sec.SSdssp, sec.SAdssp, sec.SSpred, sec.SSconf, sec.Consensus,
+	}
+	hasSeqs := len(hhm.MSA.Entries) > 0
+	for _, s := range secs {
+		hasSeqs = hasSeqs || !s.IsNull()
+	}
+	if hasSeqs {
+		pf("SEQ\n")
+		for _, s := range secs {
+			if !s.IsNull() {
+				pf(">%s\n%s\n", s.Name, s.Residues)
+			}
+		}
+		for row := range hhm.MSA.Entries {
+			s := hhm.MSA.GetA3M(row)
+			pf(">%s\n%s\n", s.Name, s.Residues)
+		}
+		pf("#\n")
+	}
+
+	emits := func(ep EProbs) {
+		for i, r := range alphabet {
+			if i > 0 {
+				pf("\t")
+			}
+			pf("%s", formatHHMProb(ep.Lookup(r)))
+		}
+	}
+	trans := func(node HMMNode) {
+		t := node.Transitions
+		pf("       ")
+		for i, p := range []Prob{t.MM, t.MI, t.MD, t.IM, t.II, t.DM, t.DD} {
+			if i > 0 {
+				pf("\t")
+			}
+			pf("%s", formatHHMProb(p))
+		}
+		for _, neff := range []Prob{node.NeffM, node.NeffI, node.NeffD} {
+			pf("\t%s", formatHHMNeff(neff))
+		}
+		pf("\n")
+	}
+
+	null := hhm.HMM.Null
+	if len(null.Probs) == 0 {
+		null = nodes[0].InsEmit
+	}
+	pf("NULL   ")
+	emits(null)
+	pf("\n")
+	pf("HMM    %s\n", strings.Join(strings.Split(alphabet.String(), ""), "\t"))
+	pf("       M->M\tM->I\tM->D\tI->M\tI->I\tD->M\tD->D\t" +
+		"Neff\tNeff_I\tNeff_D\n")
+	trans(nodes[0])
+	for i := 1; i < len(nodes); i++ {
+		residue := nodes[i].Residue
+		if residue == 0 {
+			residue = 'X'
+		}
+		pf("%c %-5d", rune(residue), i)
+		emits(nodes[i].MatEmit)
+		pf("\t%d\n", i)
+		trans(nodes[i])
+		pf("\n")
+	}
+	pf("//\n")
+	return buf.Flush()
+}
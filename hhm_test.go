@@ -0,0 +1,97 @@
+package seq
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var hhmTest = "HHsearch 1.5\n" +
+	"NAME  tiny test model\n" +
+	"FAM   a.1.1.1\n" +
+	"COM   hhmake -i tiny.a3m\n" +
+	"DATE  Mon Mar  3 12:00:00 2014\n" +
+	"LENG  3 match states, 3 columns in multiple alignment\n" +
+	"FILT  2 out of 3 sequences passed filter\n" +
+	"NEFF  1.8\n" +
+	"SEQ\n" +
+	">ss_pred\n" +
+	"CHC\n" +
+	">ss_conf\n" +
+	"898\n" +
+	">tiny\n" +
+	"ACD\n" +
+	">other\n" +
+	"AkkC-\n" +
+	"#\n" +
+	"NULL   1000\t2000\t3000\t4000\n" +
+	"HMM    A\tC\tD\tE\n" +
+	"       M->M\tM->I\tM->D\tI->M\tI->I\tD->M\tD->D\tNeff\tNeff_I\tNeff_D\n" +
+	"       0\t*\t*\t0\t*\t0\t*\t*\t*\t*\n" +
+	"A 1    0\t*\t*\t*\t1\n" +
+	"       0\t*\t*\t*\t*\t*\t*\t1000\t0\t0\n" +
+	"\n" +
+	"C 2    *\t1000\t*\t1000\t2\n" +
+	"       152\t3237\t*\t0\t*\t*\t*\t1800\t1000\t0\n" +
+	"\n" +
+	"D 3    *\t*\t0\t*\t3\n" +
+	"       0\t*\t*\t*\t*\t*\t*\t1800\t0\t0\n" +
+	"\n" +
+	"//\n"
+
+func TestReadHHM(t *testing.T) {
+	hhm, err := ReadHHM(strings.NewReader(hhmTest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hhm.Meta.Name != "tiny test model" || hhm.Meta.Leng != 3 ||
+		hhm.Meta.Neff != 1.8 {
+		t.Fatalf("Incorrect header: %#v", hhm.Meta)
+	}
+	testEqualSeq(t, hhm.Secondary.SSpred.Residues, []Residue("CHC"))
+	if !hhm.Secondary.SSdssp.IsNull() {
+		t.Fatalf("Expected no DSSP secondary structure.")
+	}
+	testEqualAlign(t, hhm.MSA, makeMSA(makeSeqs([]string{"A..CD", "AkkC-"})))
+
+	nodes := hhm.HMM.Nodes
+	if len(nodes) != 4 {
+		t.Fatalf("Expected 4 nodes (including begin) but got %d.", len(nodes))
+	}
+	if p := hhm.HMM.Null.Lookup('C'); !closeProb(p, 2*math.Ln2) {
+		t.Fatalf("Expected NULL emission of C to be 2 bits but got %s.", p)
+	}
+	if p := nodes[2].MatEmit.Lookup('C'); !closeProb(p, math.Ln2) {
+		t.Fatalf("Expected match emission of C to be 1 bit but got %s.", p)
+	}
+	if !nodes[2].MatEmit.Lookup('A').IsMin() {
+		t.Fatalf("Expected minimal match emission of A in node 2.")
+	}
+	if !reflect.DeepEqual(nodes[3].InsEmit, hhm.HMM.Null) {
+		t.Fatalf("Expected insertion emissions to equal the NULL model.")
+	}
+	if nodes[2].Residue != 'C' || nodes[2].NeffM != 1.8 ||
+		nodes[2].NeffI != 1.0 {
+		t.Fatalf("Incorrect residue or Neff values for node 2.")
+	}
+}
+
+func TestWriteHHM(t *testing.T) {
+	hhm, err := ReadHHM(strings.NewReader(hhmTest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := WriteHHM(buf, hhm); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != hhmTest {
+		t.Fatalf("Expected\n%s\nbut got\n%s", hhmTest, buf.String())
+	}
+}
+
+func closeProb(p Prob, f float64) bool {
+	return math.Abs(float64(p)-f) < 1e-9
+}
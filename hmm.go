@@ -14,6 +14,13 @@ const (
 	Insertion
 	Begin
 	End
+
+	// The special states of a Plan7 search profile that emit the residues
+	// before the first domain, after the last domain and between domains.
+	// (See Plan7Profile.)
+	NTerminal
+	CTerminal
+	Joining
 )
 
 type HMMState int
@@ -81,7 +88,7 @@ func (t *DynamicTable) reset() {
 // If you're running Viterbi in a performance critical section, ViterbiScoreMem
 // may be appropriate.
 //
-// Note that the state path is not computed.
+// Note that the state path is not computed. Use ViterbiPath to compute it.
 func (hmm *HMM) ViterbiScore(seq Sequence) Prob {
 	table := AllocTable(len(hmm.Nodes), seq.Len())
 	return hmm.ViterbiScoreMem(seq, table)
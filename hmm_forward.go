@@ -0,0 +1,179 @@
+package seq
+
+import (
+	"math"
+)
+
+// Forward returns the probability of the sequence according to the HMM,
+// summed over every path through the HMM. (The paths are the same as those
+// considered by ViterbiPath.) The probability is computed in log space with
+// a stable log-sum-exp, so long sequences don't underflow.
+//
+// If there is no path through the HMM for the sequence, then the minimum
+// probability is returned.
+//
+// If you're running Forward in a performance critical section, ForwardMem
+// may be appropriate.
+func (hmm *HMM) Forward(seq Sequence) Prob {
+	table := AllocTable(len(hmm.Nodes), seq.Len())
+	return hmm.ForwardMem(seq, table)
+}
+
+// ForwardMem is the same as Forward, except it uses a pre-allocated dynamic
+// programming table created by AllocTable, in the same way as
+// ViterbiScoreMem.
+//
+// When ForwardMem returns, the table holds the forward probabilities: the
+// probability of emitting the first obs residues of the sequence and ending
+// in the given state of the given node.
+//
+// Note that the caller must ensure that only one goroutine is calling
+// ForwardMem with the same dynamic programming table.
+func (hmm *HMM) ForwardMem(seq Sequence, table *DynamicTable) Prob {
+	if len(hmm.Nodes) == 0 {
+		return MinProb
+	}
+	table.reset()
+	M, L := len(hmm.Nodes)-1, seq.Len()
+	get := func(state HMMState, node, obs int) Prob {
+		return table.scores[table.index(state, node, obs)]
+	}
+	put := func(state HMMState, node, obs int, p Prob) {
+		table.scores[table.index(state, node, obs)] = p
+	}
+
+	// As in ViterbiPathMem, the begin state is the match state of node 0.
+	put(Match, 0, 0, 0)
+	for obs := 0; obs <= L; obs++ {
+		for node := 0; node <= M; node++ {
+			if obs > 0 {
+				residue := seq.Residues[obs-1]
+				if node > 0 {
+					trans := hmm.Nodes[node-1].Transitions
+					p := probSum(
+						get(Match, node-1, obs-1)+trans.MM,
+						probSum(
+							get(Insertion, node-1, obs-1)+trans.IM,
+							get(Deletion, node-1, obs-1)+trans.DM))
+					put(Match, node, obs,
+						p+hmm.Nodes[node].MatEmit.Lookup(residue))
+				}
+				trans := hmm.Nodes[node].Transitions
+				p := probSum(
+					get(Match, node, obs-1)+trans.MI,
+					get(Insertion, node, obs-1)+trans.II)
+				put(Insertion, node, obs,
+					p+hmm.Nodes[node].InsEmit.Lookup(residue))
+			}
+			if node > 0 {
+				trans := hmm.Nodes[node-1].Transitions
+				put(Deletion, node, obs, probSum(
+					get(Match, node-1, obs)+trans.MD,
+					get(Deletion, node-1, obs)+trans.DD))
+			}
+		}
+	}
+
+	trans := hmm.Nodes[M].Transitions
+	total := probSum(
+		get(Match, M, L)+trans.MM,
+		probSum(
+			get(Insertion, M, L)+trans.IM,
+			get(Deletion, M, L)+trans.DM))
+	if total >= MinProb {
+		return MinProb
+	}
+	return total
+}
+
+// Backward computes the same probability as Forward, but with the backward
+// algorithm. It is mostly useful in combination with Forward to compute
+// posterior probabilities.
+//
+// If you're running Backward in a performance critical section, BackwardMem
+// may be appropriate.
+func (hmm *HMM) Backward(seq Sequence) Prob {
+	table := AllocTable(len(hmm.Nodes), seq.Len())
+	return hmm.BackwardMem(seq, table)
+}
+
+// BackwardMem is the same as Backward, except it uses a pre-allocated
+// dynamic programming table created by AllocTable, in the same way as
+// ViterbiScoreMem.
+//
+// When BackwardMem returns, the table holds the backward probabilities: the
+// probability of emitting the rest of the sequence (after the first obs
+// residues) and reaching the end state, starting from the given state of
+// the given node.
+//
+// Note that the caller must ensure that only one goroutine is calling
+// BackwardMem with the same dynamic programming table.
+func (hmm *HMM) BackwardMem(seq Sequence, table *DynamicTable) Prob {
+	if len(hmm.Nodes) == 0 {
+		return MinProb
+	}
+	table.reset()
+	M, L := len(hmm.Nodes)-1, seq.Len()
+	get := func(state HMMState, node, obs int) Prob {
+		return table.scores[table.index(state, node, obs)]
+	}
+	put := func(state HMMState, node, obs int, p Prob) {
+		table.scores[table.index(state, node, obs)] = p
+	}
+
+	// nextM, nextI and nextD are the probabilities of moving to the match
+	// state of the next node, the insertion state of this node or the
+	// deletion state of the next node, and continuing from there.
+	for obs := L; obs >= 0; obs-- {
+		for node := M; node >= 0; node-- {
+			trans := hmm.Nodes[node].Transitions
+			nextM, nextI, nextD := MinProb, MinProb, MinProb
+			if obs < L {
+				residue := seq.Residues[obs]
+				if node < M {
+					nextM = hmm.Nodes[node+1].MatEmit.Lookup(residue) +
+						get(Match, node+1, obs+1)
+				}
+				nextI = hmm.Nodes[node].InsEmit.Lookup(residue) +
+					get(Insertion, node, obs+1)
+			}
+			if node < M {
+				nextD = get(Deletion, node+1, obs)
+			}
+			if node == M && obs == L {
+				// The only way out of the last node is the end state, which
+				// is also entered with the match transitions.
+				nextM = 0
+			}
+
+			put(Match, node, obs, probSum(trans.MM+nextM,
+				probSum(trans.MI+nextI, trans.MD+nextD)))
+			put(Insertion, node, obs, probSum(trans.IM+nextM,
+				trans.II+nextI))
+			if node > 0 {
+				put(Deletion, node, obs, probSum(trans.DM+nextM,
+					trans.DD+nextD))
+			}
+		}
+	}
+
+	total := get(Match, 0, 0)
+	if total >= MinProb {
+		return MinProb
+	}
+	return total
+}
+
+// probSum returns the sum of two probabilities, using log-sum-exp so that
+// the result is accurate even when both probabilities are tiny. Values at
+// least as large as MinProb (which can result from adding to MinProb) are
+// treated as a probability of zero.
+func probSum(p1, p2 Prob) Prob {
+	if p1 > p2 {
+		p1, p2 = p2, p1
+	}
+	if p2 >= MinProb {
+		return p1
+	}
+	return p1 - Prob(math.Log1p(math.Exp(float64(p1-p2))))
+}
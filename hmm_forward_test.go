@@ -0,0 +1,81 @@
+package seq
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// bruteForward sums the probabilities of every path through the HMM for the
+// sequence by enumerating them.
+func bruteForward(hmm *HMM, seq Sequence) float64 {
+	M, L := len(hmm.Nodes)-1, seq.Len()
+	total := 0.0
+	var walk func(state HMMState, node, obs int, p Prob)
+	walk = func(state HMMState, node, obs int, p Prob) {
+		t := hmm.Nodes[node].Transitions
+		var mm, mi, md Prob
+		switch state {
+		case Match:
+			mm, mi, md = t.MM, t.MI, t.MD
+		case Insertion:
+			mm, mi, md = t.IM, t.II, MinProb
+		case Deletion:
+			mm, mi, md = t.DM, MinProb, t.DD
+		}
+		if node == M {
+			if obs == L {
+				total += (p + mm).Ratio()
+			}
+		} else {
+			if obs < L {
+				emit := hmm.Nodes[node+1].MatEmit.Lookup(seq.Residues[obs])
+				walk(Match, node+1, obs+1, p+mm+emit)
+			}
+			walk(Deletion, node+1, obs, p+md)
+		}
+		if obs < L {
+			emit := hmm.Nodes[node].InsEmit.Lookup(seq.Residues[obs])
+			walk(Insertion, node, obs+1, p+mi+emit)
+		}
+	}
+	walk(Match, 0, 0, 0)
+	return total
+}
+
+func TestForwardBackward(t *testing.T) {
+	hmms, err := ReadHMMER(strings.NewReader(hmmerTest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmm := hmms[0].HMM
+
+	for _, s := range []string{"", "A", "AG", "ACG", "ACTG", "TTACGA"} {
+		seq := NewSequenceString(s, s)
+		forward, backward := hmm.Forward(seq), hmm.Backward(seq)
+		if math.Abs(float64(forward-backward)) > 1e-9 {
+			t.Fatalf("Expected forward %s and backward %s to be equal for "+
+				"'%s'.", forward, backward, s)
+		}
+		expected := -math.Log(bruteForward(hmm, seq))
+		if math.Abs(float64(forward)-expected) > 1e-9 {
+			t.Fatalf("Expected forward %f but got %s for '%s'.",
+				expected, forward, s)
+		}
+		if viterbi, _ := hmm.ViterbiPath(seq); forward.Less(viterbi) {
+			t.Fatalf("Forward %s is less probable than Viterbi %s for '%s'.",
+				forward, viterbi, s)
+		}
+	}
+
+	// Long sequences must not underflow.
+	long := NewSequenceString("long", strings.Repeat("ACG", 500))
+	forward, backward := hmm.Forward(long), hmm.Backward(long)
+	if forward.IsMin() || math.IsInf(float64(forward), 0) {
+		t.Fatalf("Expected a finite forward probability but got %s.", forward)
+	}
+	if math.Abs(float64(forward-backward)) > 1e-6 {
+		t.Fatalf("Expected forward %s and backward %s to be equal.",
+			forward, backward)
+	}
+}
@@ -0,0 +1,251 @@
+package seq
+
+import (
+	"math"
+)
+
+// HMMPosterior holds the posterior probabilities of the emitting states of
+// an HMM for each residue of a sequence. That is, the probability that the
+// residue was emitted by the state, given the entire sequence, summed over
+// every path through the HMM.
+//
+// Unlike most probabilities in this package, posterior probabilities are
+// stored as plain probabilities in the range [0, 1] rather than as Probs.
+type HMMPosterior struct {
+	// Match[i][k] is the posterior probability that residue i was emitted by
+	// the match state of node k. (Match[i][0] is always 0, since the begin
+	// node has no match state.)
+	Match [][]float64
+
+	// Insertion[i][k] is the posterior probability that residue i was
+	// emitted by the insertion state of node k.
+	Insertion [][]float64
+
+	// The probability of the sequence, as computed by Forward.
+	Prob Prob
+}
+
+// Posterior computes the posterior probabilities of the match and insertion
+// states of the HMM for every residue of the sequence, with the forward and
+// backward algorithms. For each residue, the probabilities sum to 1.
+//
+// If there is no path through the HMM for the sequence, then every posterior
+// probability is 0 and Prob is the minimum probability.
+func (hmm *HMM) Posterior(seq Sequence) HMMPosterior {
+	post, table, total := hmm.posteriors(seq)
+	M := len(hmm.Nodes) - 1
+	hp := HMMPosterior{
+		Match:     make([][]float64, seq.Len()),
+		Insertion: make([][]float64, seq.Len()),
+		Prob:      total,
+	}
+	for i := range hp.Match {
+		hp.Match[i] = make([]float64, M+1)
+		hp.Insertion[i] = make([]float64, M+1)
+		if post == nil {
+			continue
+		}
+		for k := 0; k <= M; k++ {
+			if k > 0 {
+				hp.Match[i][k] = post[table.index(Match, k, i+1)]
+			}
+			hp.Insertion[i][k] = post[table.index(Insertion, k, i+1)]
+		}
+	}
+	return hp
+}
+
+// posteriors returns the posterior probability of every state, laid out in
+// the same way as the given dynamic programming table, along with the
+// probability of the sequence. The cell for a state after emitting obs
+// residues holds the probability that the path visits that state at that
+// point. If there is no path, then the posteriors are nil.
+func (hmm *HMM) posteriors(seq Sequence) ([]float64, *DynamicTable, Prob) {
+	forward := AllocTable(len(hmm.Nodes), seq.Len())
+	backward := AllocTable(len(hmm.Nodes), seq.Len())
+	total := hmm.ForwardMem(seq, forward)
+	if total.IsMin() {
+		return nil, forward, total
+	}
+	hmm.BackwardMem(seq, backward)
+
+	post := make([]float64, len(forward.scores))
+	for i := range post {
+		f, b := forward.scores[i], backward.scores[i]
+		if f < MinProb && b < MinProb {
+			post[i] = math.Exp(-float64(f + b - total))
+		}
+	}
+	return post, forward, total
+}
+
+// HMMAlignment is an alignment of a sequence to an HMM, along with the
+// confidence in each step of the alignment.
+type HMMAlignment struct {
+	Path HMMPath
+
+	// The posterior probability of each step of the path: the probability
+	// that the residue is emitted by the state of the step, or for deletion
+	// states, that the path visits the state at that point.
+	Confidence []float64
+
+	// The expected accuracy of the alignment, which is the sum of the
+	// confidences of the steps that emit residues.
+	Accuracy float64
+}
+
+// MEAAlign computes the maximum expected accuracy alignment of the sequence
+// to the HMM, as HMMER's hmmalign does. This is the path through the HMM
+// (using only transitions that are possible) that maximizes the sum of the
+// posterior probabilities of the residues it emits. It often aligns
+// residues more accurately than the Viterbi path, particularly for distant
+// homologs.
+//
+// The paths considered are the same as those considered by ViterbiPath. If
+// there is no path through the HMM for the sequence, then the alignment
+// returned has a nil path.
+func (hmm *HMM) MEAAlign(seq Sequence) HMMAlignment {
+	post, table, _ := hmm.posteriors(seq)
+	if post == nil {
+		return HMMAlignment{}
+	}
+	M, L := len(hmm.Nodes)-1, seq.Len()
+	inf := math.Inf(-1)
+	acc := make([]float64, len(post))
+	for i := range acc {
+		acc[i] = inf
+	}
+
+	// from returns the accuracy of the state at the cell given, or -inf if
+	// the transition from it is impossible.
+	from := func(state HMMState, node, obs int, trans Prob) float64 {
+		if trans >= MinProb {
+			return inf
+		}
+		return acc[table.index(state, node, obs)]
+	}
+	match := func(node, obs int) (float64, HMMState) {
+		trans := hmm.Nodes[node-1].Transitions
+		return meaBest(
+			from(Match, node-1, obs-1, trans.MM),
+			from(Insertion, node-1, obs-1, trans.IM),
+			from(Deletion, node-1, obs-1, trans.DM))
+	}
+	insertion := func(node, obs int) (float64, HMMState) {
+		trans := hmm.Nodes[node].Transitions
+		return meaBest(
+			from(Match, node, obs-1, trans.MI),
+			from(Insertion, node, obs-1, trans.II),
+			inf)
+	}
+	deletion := func(node, obs int) (float64, HMMState) {
+		trans := hmm.Nodes[node-1].Transitions
+		return meaBest(
+			from(Match, node-1, obs, trans.MD),
+			inf,
+			from(Deletion, node-1, obs, trans.DD))
+	}
+
+	// States that are never visited by a possible path are excluded, so
+	// that the path found is possible.
+	acc[table.index(Match, 0, 0)] = 0
+	for obs := 0; obs <= L; obs++ {
+		for node := 0; node <= M; node++ {
+			m := table.index(Match, node, obs)
+			i := table.index(Insertion, node, obs)
+			d := table.index(Deletion, node, obs)
+			if obs > 0 && node > 0 && post[m] > 0 {
+				a, _ := match(node, obs)
+				acc[m] = a + post[m]
+			}
+			if obs > 0 && post[i] > 0 {
+				a, _ := insertion(node, obs)
+				acc[i] = a + post[i]
+			}
+			if node > 0 && post[d] > 0 {
+				acc[d], _ = deletion(node, obs)
+			}
+		}
+	}
+
+	trans := hmm.Nodes[M].Transitions
+	accuracy, state := meaBest(
+		from(Match, M, L, trans.MM),
+		from(Insertion, M, L, trans.IM),
+		from(Deletion, M, L, trans.DM))
+	if math.IsInf(accuracy, -1) {
+		// This can only happen through a loss of precision.
+		return HMMAlignment{}
+	}
+
+	aligned := HMMAlignment{
+		Path:       make(HMMPath, 0, M+L),
+		Confidence: make([]float64, 0, M+L),
+		Accuracy:   accuracy,
+	}
+	node, obs := M, L
+	for !(state == Match && node == 0) {
+		aligned.Confidence = append(aligned.Confidence,
+			post[table.index(state, node, obs)])
+		switch state {
+		case Match:
+			aligned.Path = append(aligned.Path, HMMStep{Match, node, obs - 1})
+			_, state = match(node, obs)
+			node, obs = node-1, obs-1
+		case Insertion:
+			aligned.Path = append(aligned.Path,
+				HMMStep{Insertion, node, obs - 1})
+			_, state = insertion(node, obs)
+			obs--
+		case Deletion:
+			aligned.Path = append(aligned.Path, HMMStep{Deletion, node, -1})
+			_, state = deletion(node, obs)
+			node--
+		}
+	}
+	for i, j := 0, len(aligned.Path)-1; i < j; i, j = i+1, j-1 {
+		aligned.Path[i], aligned.Path[j] = aligned.Path[j], aligned.Path[i]
+		aligned.Confidence[i], aligned.Confidence[j] =
+			aligned.Confidence[j], aligned.Confidence[i]
+	}
+	return aligned
+}
+
+// meaBest returns the largest of the accuracies of coming from a match,
+// insertion or deletion state (in that order of preference when there are
+// ties), and that state.
+func meaBest(m, i, d float64) (float64, HMMState) {
+	switch {
+	case m >= i && m >= d:
+		return m, Match
+	case i >= d:
+		return i, Insertion
+	}
+	return d, Deletion
+}
+
+// A2M renders the alignment in A2M format. It is the same as calling A2M on
+// the path.
+func (a HMMAlignment) A2M(hmm *HMM, seq Sequence) Sequence {
+	return a.Path.A2M(hmm, seq)
+}
+
+// PP returns the posterior probability annotation of the alignment, in
+// correspondence with the columns of its A2M rendering. This is the same as
+// the "#=GR PP" lines written by HMMER: each residue is annotated with its
+// confidence rounded to the nearest tenth, as a digit from 0 to 9, or with
+// '*' if its confidence is at least 0.95. Gaps are annotated with '.'.
+func (a HMMAlignment) PP(hmm *HMM) string {
+	bs := make([]byte, 0, len(a.Path)+len(hmm.Nodes))
+	a.Path.columns(hmm, func(s int) {
+		switch {
+		case s < 0 || a.Path[s].State == Deletion:
+			bs = append(bs, '.')
+		case a.Confidence[s]+0.05 >= 1:
+			bs = append(bs, '*')
+		default:
+			bs = append(bs, '0'+byte((a.Confidence[s]+0.05)*10))
+		}
+	})
+	return string(bs)
+}
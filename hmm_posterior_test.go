@@ -0,0 +1,89 @@
+package seq
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPosterior(t *testing.T) {
+	hmms, err := ReadHMMER(strings.NewReader(hmmerTest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmm := hmms[0].HMM
+
+	for _, s := range []string{"ACG", "ACTG", "TTACGA", "AAAAAA"} {
+		seq := NewSequenceString(s, s)
+		post := hmm.Posterior(seq)
+		if math.Abs(float64(post.Prob-hmm.Forward(seq))) > 1e-12 {
+			t.Fatalf("Expected probability %s but got %s for '%s'.",
+				hmm.Forward(seq), post.Prob, s)
+		}
+		for i := range seq.Residues {
+			sum := 0.0
+			for k := range post.Match[i] {
+				sum += post.Match[i][k] + post.Insertion[i][k]
+			}
+			if math.Abs(sum-1) > 1e-9 {
+				t.Fatalf("Expected posteriors of residue %d of '%s' to sum "+
+					"to 1 but got %f.", i, s, sum)
+			}
+		}
+	}
+}
+
+func TestMEAAlign(t *testing.T) {
+	hmms, err := ReadHMMER(strings.NewReader(hmmerTest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmm := hmms[0].HMM
+
+	tests := []struct {
+		seq, a2m, pp string
+	}{
+		{"ACG", "ACG", "***"},
+		{"ACTG", "ACtG", ""},
+		{"AG", "A-G", ""},
+		{"AC", "AC-", ""},
+	}
+	for _, test := range tests {
+		seq := NewSequenceString(test.seq, test.seq)
+		aligned := hmm.MEAAlign(seq)
+		if got := string(aligned.A2M(hmm, seq).Bytes()); got != test.a2m {
+			t.Fatalf("Expected A2M '%s' for '%s' but got '%s'.",
+				test.a2m, test.seq, got)
+		}
+
+		pp := aligned.PP(hmm)
+		if len(pp) != len(test.a2m) {
+			t.Fatalf("Expected PP '%s' to have the same length as '%s'.",
+				pp, test.a2m)
+		}
+		for i := range pp {
+			if (pp[i] == '.') != (test.a2m[i] == '-') {
+				t.Fatalf("PP '%s' doesn't agree with A2M '%s'.", pp, test.a2m)
+			}
+		}
+		if test.pp != "" && pp != test.pp {
+			t.Fatalf("Expected PP '%s' for '%s' but got '%s'.",
+				test.pp, test.seq, pp)
+		}
+
+		post := hmm.Posterior(seq)
+		accuracy := 0.0
+		for _, step := range aligned.Path {
+			switch step.State {
+			case Match:
+				accuracy += post.Match[step.Obs][step.Node]
+			case Insertion:
+				accuracy += post.Insertion[step.Obs][step.Node]
+			}
+		}
+		if math.Abs(accuracy-aligned.Accuracy) > 1e-9 {
+			t.Fatalf("Expected accuracy %f but got %f for '%s'.",
+				accuracy, aligned.Accuracy, test.seq)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package seq
+
+import (
+	"fmt"
+)
+
+// HMMStep is a single step in a path through an HMM. It is a visit to the
+// match, deletion or insertion state of a node, along with the index of the
+// residue emitted by that state. Deletion states don't emit a residue, so
+// their Obs is -1.
+//
+// Match and deletion states belong to nodes 1 through M, while insertion
+// states belong to nodes 0 through M (where node 0 is the begin node, whose
+// insertion state emits residues before the first match state).
+type HMMStep struct {
+	State HMMState
+	Node  int
+	Obs   int
+}
+
+func (step HMMStep) String() string {
+	var c byte
+	switch step.State {
+	case Match:
+		c = 'M'
+	case Deletion:
+		c = 'D'
+	case Insertion:
+		c = 'I'
+	case Begin:
+		c = 'B'
+	case End:
+		c = 'E'
+	case NTerminal:
+		c = 'N'
+	case CTerminal:
+		c = 'C'
+	case Joining:
+		c = 'J'
+	default:
+		c = '?'
+	}
+	return fmt.Sprintf("%c%d:%d", c, step.Node, step.Obs)
+}
+
+// HMMPath is a path through an HMM, in order. The begin and end states are
+// not included.
+type HMMPath []HMMStep
+
+// ViterbiPath returns the probability of the likeliest path through the HMM
+// for the given sequence, along with the path itself. The path starts at the
+// begin node, visits the match or deletion state of every node in order,
+// and emits every residue of the sequence.
+//
+// If there is no path through the HMM for the sequence, then the minimum
+// probability and a nil path are returned.
+//
+// If you're running Viterbi in a performance critical section, ViterbiPathMem
+// may be appropriate.
+func (hmm *HMM) ViterbiPath(seq Sequence) (Prob, HMMPath) {
+	table := AllocTable(len(hmm.Nodes), seq.Len())
+	return hmm.ViterbiPathMem(seq, table)
+}
+
+// ViterbiPathMem is the same as ViterbiPath, except it uses a pre-allocated
+// dynamic programming table created by AllocTable, in the same way as
+// ViterbiScoreMem.
+//
+// Note that the caller must ensure that only one goroutine is calling
+// ViterbiPathMem with the same dynamic programming table.
+func (hmm *HMM) ViterbiPathMem(
+	seq Sequence,
+	table *DynamicTable,
+) (Prob, HMMPath) {
+	if len(hmm.Nodes) == 0 {
+		return MinProb, nil
+	}
+	table.reset()
+	M, L := len(hmm.Nodes)-1, seq.Len()
+	get := func(state HMMState, node, obs int) Prob {
+		return table.scores[table.index(state, node, obs)]
+	}
+	put := func(state HMMState, node, obs int, p Prob) {
+		table.scores[table.index(state, node, obs)] = p
+	}
+
+	// The begin state is stored as the match state of node 0. Unlike
+	// ViterbiScoreMem, the end state doesn't emit a residue, so every cell
+	// (including those for the last residue) must be computed before
+	// transitioning to it.
+	put(Match, 0, 0, 0)
+	for obs := 0; obs <= L; obs++ {
+		for node := 0; node <= M; node++ {
+			if obs > 0 {
+				residue := seq.Residues[obs-1]
+				if node > 0 {
+					p, _ := hmm.viterbiMatch(table, node, obs)
+					put(Match, node, obs,
+						p+hmm.Nodes[node].MatEmit.Lookup(residue))
+				}
+				p, _ := hmm.viterbiInsertion(table, node, obs)
+				put(Insertion, node, obs,
+					p+hmm.Nodes[node].InsEmit.Lookup(residue))
+			}
+			if node > 0 {
+				p, _ := hmm.viterbiDeletion(table, node, obs)
+				put(Deletion, node, obs, p)
+			}
+		}
+	}
+
+	trans := hmm.Nodes[M].Transitions
+	score, state := viterbiBest(
+		get(Match, M, L)+trans.MM,
+		get(Insertion, M, L)+trans.IM,
+		get(Deletion, M, L)+trans.DM)
+	if score >= MinProb {
+		return MinProb, nil
+	}
+
+	path := make(HMMPath, 0, M+L)
+	node, obs := M, L
+	for !(state == Match && node == 0) {
+		switch state {
+		case Match:
+			path = append(path, HMMStep{Match, node, obs - 1})
+			_, state = hmm.viterbiMatch(table, node, obs)
+			node, obs = node-1, obs-1
+		case Insertion:
+			path = append(path, HMMStep{Insertion, node, obs - 1})
+			_, state = hmm.viterbiInsertion(table, node, obs)
+			obs--
+		case Deletion:
+			path = append(path, HMMStep{Deletion, node, -1})
+			_, state = hmm.viterbiDeletion(table, node, obs)
+			node--
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return score, path
+}
+
+// viterbiMatch returns the probability of the likeliest transition into the
+// match state of the node (before emitting the residue obs-1), and the state
+// it comes from.
+func (hmm *HMM) viterbiMatch(
+	t *DynamicTable,
+	node, obs int,
+) (Prob, HMMState) {
+	trans := hmm.Nodes[node-1].Transitions
+	return viterbiBest(
+		t.scores[t.index(Match, node-1, obs-1)]+trans.MM,
+		t.scores[t.index(Insertion, node-1, obs-1)]+trans.IM,
+		t.scores[t.index(Deletion, node-1, obs-1)]+trans.DM)
+}
+
+// viterbiInsertion is like viterbiMatch, but for the insertion state.
+// (There are no transitions from deletion to insertion states in Plan7.)
+func (hmm *HMM) viterbiInsertion(
+	t *DynamicTable,
+	node, obs int,
+) (Prob, HMMState) {
+	trans := hmm.Nodes[node].Transitions
+	return viterbiBest(
+		t.scores[t.index(Match, node, obs-1)]+trans.MI,
+		t.scores[t.index(Insertion, node, obs-1)]+trans.II,
+		MinProb)
+}
+
+// viterbiDeletion is like viterbiMatch, but for the deletion state, which
+// doesn't emit a residue. (There are no transitions from insertion to
+// deletion states in Plan7.)
+func (hmm *HMM) viterbiDeletion(
+	t *DynamicTable,
+	node, obs int,
+) (Prob, HMMState) {
+	trans := hmm.Nodes[node-1].Transitions
+	return viterbiBest(
+		t.scores[t.index(Match, node-1, obs)]+trans.MD,
+		MinProb,
+		t.scores[t.index(Deletion, node-1, obs)]+trans.DD)
+}
+
+// viterbiBest returns the likeliest of the probabilities of coming from a
+// match, insertion or deletion state (in that order of preference when there
+// are ties), and that state.
+func viterbiBest(m, i, d Prob) (Prob, HMMState) {
+	switch {
+	case m <= i && m <= d:
+		return m, Match
+	case i <= d:
+		return i, Insertion
+	}
+	return d, Deletion
+}
+
+// A2M renders the path as the aligned sequence in A2M format, as used by
+// MSA.Add. Residues emitted by match states are in upper case, residues
+// emitted by insertion states are in lower case and deletion states are
+// written as '-'. The sequence must be the same one that the path was
+// computed for, and the HMM is used to determine the number of match states.
+//
+// Nodes that the path doesn't visit are written as deletions, and residues
+// that the path doesn't emit (or that are emitted by the special states of a
+// Plan7Profile) are omitted. A path with more than one domain should be
+// split with Domains first.
+func (path HMMPath) A2M(hmm *HMM, seq Sequence) Sequence {
+	rs := make([]Residue, 0, len(path)+len(hmm.Nodes))
+	path.columns(hmm, func(s int) {
+		switch {
+		case s < 0 || path[s].State == Deletion:
+			rs = append(rs, '-')
+		case path[s].State == Match:
+			rs = append(rs, residueUpper(seq.Residues[path[s].Obs]))
+		default:
+			rs = append(rs, residueLower(seq.Residues[path[s].Obs]))
+		}
+	})
+	return Sequence{Name: seq.Name, Residues: rs}
+}
+
+// columns calls column once for every column of the A2M rendering of the
+// path, in order, with the index of the step in the path that is written
+// there or -1 for a node that the path doesn't visit.
+func (path HMMPath) columns(hmm *HMM, column func(s int)) {
+	next := 1 // the next node whose column hasn't been written
+	skipTo := func(node int) {
+		for ; next < node; next++ {
+			column(-1)
+		}
+	}
+	for s, step := range path {
+		switch step.State {
+		case Match, Deletion:
+			skipTo(step.Node)
+			column(s)
+			next++
+		case Insertion:
+			skipTo(step.Node + 1)
+			column(s)
+		}
+	}
+	skipTo(len(hmm.Nodes))
+}
+
+func residueUpper(r Residue) Residue {
+	if r >= 'a' && r <= 'z' {
+		return r - 'a' + 'A'
+	}
+	return r
+}
+
+func residueLower(r Residue) Residue {
+	if r >= 'A' && r <= 'Z' {
+		return r - 'A' + 'a'
+	}
+	return r
+}
@@ -0,0 +1,101 @@
+package seq
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// pathProb computes the probability of the given path through the HMM
+// directly from its transitions and emissions.
+func pathProb(hmm *HMM, seq Sequence, path HMMPath) Prob {
+	p := Prob(0)
+	prevState, prevNode := Match, 0
+	trans := func(to HMMState) Prob {
+		t := hmm.Nodes[prevNode].Transitions
+		switch {
+		case prevState == Match && to == Match:
+			return t.MM
+		case prevState == Match && to == Insertion:
+			return t.MI
+		case prevState == Match && to == Deletion:
+			return t.MD
+		case prevState == Insertion && to == Match:
+			return t.IM
+		case prevState == Insertion && to == Insertion:
+			return t.II
+		case prevState == Deletion && to == Match:
+			return t.DM
+		case prevState == Deletion && to == Deletion:
+			return t.DD
+		}
+		return MinProb
+	}
+	for _, step := range path {
+		p += trans(step.State)
+		switch step.State {
+		case Match:
+			p += hmm.Nodes[step.Node].MatEmit.Lookup(seq.Residues[step.Obs])
+		case Insertion:
+			p += hmm.Nodes[step.Node].InsEmit.Lookup(seq.Residues[step.Obs])
+		}
+		prevState, prevNode = step.State, step.Node
+	}
+	return p + trans(Match)
+}
+
+func TestViterbiPath(t *testing.T) {
+	hmms, err := ReadHMMER(strings.NewReader(hmmerTest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmm := hmms[0].HMM
+
+	tests := []struct {
+		seq, a2m string
+	}{
+		{"ACG", "ACG"},
+		{"ACTG", "ACtG"},
+		{"AG", "A-G"},
+		{"AC", "AC-"},
+		{"TACG", "tACG"},
+	}
+	msa := NewMSA()
+	for _, test := range tests {
+		seq := NewSequenceString(test.seq, test.seq)
+		score, path := hmm.ViterbiPath(seq)
+		if score.IsMin() {
+			t.Fatalf("Expected a path for '%s'.", test.seq)
+		}
+		if p := pathProb(hmm, seq, path); math.Abs(float64(p-score)) > 1e-9 {
+			t.Fatalf("Expected the path %v for '%s' to have probability %s "+
+				"but it has %s.", path, test.seq, score, p)
+		}
+		got := path.A2M(hmm, seq)
+		if string(got.Bytes()) != test.a2m || got.Name != test.seq {
+			t.Fatalf("Expected A2M '%s' for '%s' but got '%s'.",
+				test.a2m, test.seq, got.Bytes())
+		}
+		msa.Add(got)
+	}
+
+	expected := []string{
+		".AC.G", ".ACtG", ".A-.G", ".AC.-", "tAC.G",
+	}
+	for i, e := range expected {
+		if got := string(msa.GetA2M(i).Bytes()); got != e {
+			t.Fatalf("Expected MSA entry %d to be '%s' but got '%s'.",
+				i, e, got)
+		}
+	}
+
+	// The empty sequence must delete every node.
+	empty := NewSequenceString("empty", "")
+	if _, path := hmm.ViterbiPath(empty); len(path) != 3 {
+		t.Fatalf("Expected 3 deletions for the empty sequence but got %v.",
+			path)
+	} else if got := string(path.A2M(hmm, empty).Bytes()); got != "---" {
+		t.Fatalf("Expected A2M '---' for the empty sequence but got '%s'.",
+			got)
+	}
+}
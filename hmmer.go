@@ -0,0 +1,647 @@
+package seq
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// HMMER represents a single profile HMM read from a HMMER3 ASCII file.
+//
+// Node 0 of the HMM corresponds to the begin state of the model: its
+// insertion emissions and transitions are the "insert 0" emissions and the
+// B->M1, B->I0, B->D1 (etc.) transitions from the file. Nodes 1 through
+// LENG correspond to the match states of the model.
+type HMMER struct {
+	Meta HMMERMeta
+	HMM  *HMM
+
+	// The average match state emissions from the COMPO line. If the file
+	// has no COMPO line, then Compo has no probabilities.
+	Compo EProbs
+
+	// Per node annotations from the match emission lines. This slice is
+	// in correspondence with HMM.Nodes. (So that the first element is
+	// always empty.)
+	//
+	// Note that the consensus residue (CONS) is stored in the Residue field
+	// of each HMMNode.
+	Annotations []HMMERAnnotation
+}
+
+// HMMERMeta contains the header fields of a HMMER3 file.
+type HMMERMeta struct {
+	// The first line of the file, e.g., "HMMER3/f [3.1b2 | February 2015]".
+	Format string
+
+	Name string
+	Acc  string
+	Desc string
+	Leng int
+	Maxl int
+	Alph string
+
+	// Flags indicating which per node annotations are present.
+	RF, MM, Cons, CS, Map bool
+
+	Date  string
+	Com   []string
+	NSeq  int
+	EffN  float64
+	Cksum uint32
+
+	// Pfam gathering, trusted and noise cutoffs. Each is either nil or
+	// contains exactly two scores.
+	GA, TC, NC []float64
+
+	Stats []HMMERStats
+
+	// Any header lines that aren't recognized, in the order in which they
+	// were read.
+	Extra []string
+}
+
+// HMMERStats corresponds to a single STATS line in a HMMER3 file, which
+// contains the statistical parameters for a particular score distribution.
+type HMMERStats struct {
+	// Mode is always "LOCAL" in HMMER3.
+	Mode string
+
+	// One of "MSV", "VITERBI" or "FORWARD".
+	Distribution string
+
+	// The location (mu or tau) and slope (lambda) parameters.
+	Location, Lambda float64
+}
+
+// HMMERAnnotation contains the annotations of a single node. Annotations that
+// are not present are set to '-' (or zero for Map).
+type HMMERAnnotation struct {
+	Map int
+	RF  Residue
+	MM  Residue
+	CS  Residue
+}
+
+// HMMERReader reads HMMER3 formatted profile HMMs. An input may contain more
+// than one HMM.
+type HMMERReader struct {
+	lines *lineReader
+}
+
+// NewHMMERReader creates a new HMMER3 reader that reads from r.
+func NewHMMERReader(r io.Reader) *HMMERReader {
+	return &HMMERReader{newLineReader(r)}
+}
+
+// ReadHMMER is a convenience function for reading every HMM in a HMMER3
+// formatted input.
+func ReadHMMER(r io.Reader) ([]*HMMER, error) {
+	return NewHMMERReader(r).ReadAll()
+}
+
+// ReadAll reads all remaining HMMs in the input.
+func (r *HMMERReader) ReadAll() ([]*HMMER, error) {
+	hmms := make([]*HMMER, 0, 1)
+	for {
+		hmm, err := r.Read()
+		if err == io.EOF {
+			return hmms, nil
+		} else if err != nil {
+			return nil, err
+		}
+		hmms = append(hmms, hmm)
+	}
+}
+
+// Read reads the next HMM in the input. When there are no more HMMs, io.EOF
+// is returned.
+func (r *HMMERReader) Read() (*HMMER, error) {
+	line, err := r.lines.nextNonBlank()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "HMMER3") {
+		return nil, r.lines.errorf("expected a HMMER3 format line but got "+
+			"'%s'", line)
+	}
+
+	h := &HMMER{Meta: HMMERMeta{Format: line}}
+	alphabet, err := r.readHeader(&h.Meta)
+	if err != nil {
+		return nil, err
+	}
+
+	// The transition header line is ignored.
+	if line, err = r.mustNext(); err != nil {
+		return nil, err
+	}
+	if !strings.Contains(line, "m->m") {
+		return nil, r.lines.errorf("expected transition header but got '%s'",
+			line)
+	}
+
+	// The COMPO line is optional.
+	if line, err = r.mustNext(); err != nil {
+		return nil, err
+	}
+	if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "COMPO" {
+		h.Compo, err = r.parseEmissions(alphabet, fields[1:])
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		r.lines.unread(line)
+	}
+
+	nodes := make([]HMMNode, 0, h.Meta.Leng+1)
+	h.Annotations = make([]HMMERAnnotation, 0, h.Meta.Leng+1)
+
+	begin := HMMNode{NodeNum: 0, MatEmit: NewEProbs(alphabet)}
+	if err := r.readInsertTransitions(alphabet, &begin); err != nil {
+		return nil, err
+	}
+	nodes = append(nodes, begin)
+	h.Annotations = append(h.Annotations, HMMERAnnotation{0, '-', '-', '-'})
+
+	for {
+		if line, err = r.mustNext(); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(line) == "//" {
+			break
+		}
+
+		node := HMMNode{}
+		var anno HMMERAnnotation
+		if err := r.parseMatchLine(h, alphabet, line, &node, &anno); err != nil {
+			return nil, err
+		}
+		if node.NodeNum != len(nodes) {
+			return nil, r.lines.errorf("expected node %d but got node %d",
+				len(nodes), node.NodeNum)
+		}
+		if err := r.readInsertTransitions(alphabet, &node); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+		h.Annotations = append(h.Annotations, anno)
+	}
+	if h.Meta.Leng > 0 && len(nodes)-1 != h.Meta.Leng {
+		return nil, r.lines.errorf("LENG is %d but the model has %d nodes",
+			h.Meta.Leng, len(nodes)-1)
+	}
+	h.Meta.Leng = len(nodes) - 1
+	h.HMM = NewHMM(nodes, alphabet, EProbs{})
+	return h, nil
+}
+
+// readHeader reads the header fields up to and including the "HMM" line,
+// and returns the alphabet given on the "HMM" line.
+func (r *HMMERReader) readHeader(meta *HMMERMeta) (Alphabet, error) {
+	for {
+		line, err := r.mustNext()
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		tag := fields[0]
+		value := strings.TrimSpace(strings.TrimPrefix(line, tag))
+
+		switch tag {
+		case "HMM":
+			alphabet := make(Alphabet, len(fields)-1)
+			for i, f := range fields[1:] {
+				if len(f) != 1 {
+					return nil, r.lines.errorf("invalid residue '%s' in "+
+						"alphabet", f)
+				}
+				alphabet[i] = Residue(f[0])
+			}
+			return alphabet, nil
+		case "NAME":
+			meta.Name = value
+		case "ACC":
+			meta.Acc = value
+		case "DESC":
+			meta.Desc = value
+		case "LENG":
+			meta.Leng, err = r.parseInt(tag, value)
+		case "MAXL":
+			meta.Maxl, err = r.parseInt(tag, value)
+		case "ALPH":
+			meta.Alph = value
+		case "RF":
+			meta.RF, err = r.parseFlag(tag, value)
+		case "MM":
+			meta.MM, err = r.parseFlag(tag, value)
+		case "CONS":
+			meta.Cons, err = r.parseFlag(tag, value)
+		case "CS":
+			meta.CS, err = r.parseFlag(tag, value)
+		case "MAP":
+			meta.Map, err = r.parseFlag(tag, value)
+		case "DATE":
+			meta.Date = value
+		case "COM":
+			meta.Com = append(meta.Com, value)
+		case "NSEQ":
+			meta.NSeq, err = r.parseInt(tag, value)
+		case "EFFN":
+			meta.EffN, err = r.parseFloat(tag, value)
+		case "CKSUM":
+			var n uint64
+			n, err = strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				err = r.lines.errorf("could not parse CKSUM '%s': %s",
+					value, err)
+			}
+			meta.Cksum = uint32(n)
+		case "GA":
+			meta.GA, err = r.parseCutoffs(tag, fields[1:])
+		case "TC":
+			meta.TC, err = r.parseCutoffs(tag, fields[1:])
+		case "NC":
+			meta.NC, err = r.parseCutoffs(tag, fields[1:])
+		case "STATS":
+			if len(fields) != 5 {
+				return nil, r.lines.errorf("expected 4 fields in STATS but "+
+					"got %d", len(fields)-1)
+			}
+			stats := HMMERStats{Mode: fields[1], Distribution: fields[2]}
+			if stats.Location, err = r.parseFloat(tag, fields[3]); err != nil {
+				return nil, err
+			}
+			stats.Lambda, err = r.parseFloat(tag, fields[4])
+			meta.Stats = append(meta.Stats, stats)
+		default:
+			meta.Extra = append(meta.Extra, line)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseMatchLine parses a line containing the node number, match emissions
+// and annotations of a single node.
+func (r *HMMERReader) parseMatchLine(
+	h *HMMER,
+	alphabet Alphabet,
+	line string,
+	node *HMMNode,
+	anno *HMMERAnnotation,
+) error {
+	fields := strings.Fields(line)
+	if len(fields) < 1+len(alphabet) {
+		return r.lines.errorf("expected at least %d fields in match "+
+			"emission line but got %d", 1+len(alphabet), len(fields))
+	}
+
+	var err error
+	if node.NodeNum, err = strconv.Atoi(fields[0]); err != nil {
+		return r.lines.errorf("could not parse node number '%s': %s",
+			fields[0], err)
+	}
+	if node.MatEmit, err = r.parseEmissions(alphabet,
+		fields[1:1+len(alphabet)]); err != nil {
+		return err
+	}
+
+	// The annotation columns differ between versions of the format.
+	// HMMER3/b has MAP, RF and CS. HMMER3/e adds CONS after MAP, and
+	// HMMER3/f adds MM after RF.
+	annos := fields[1+len(alphabet):]
+	var mapf, cons, rf, mm, cs string
+	switch len(annos) {
+	case 3:
+		mapf, cons, rf, mm, cs = annos[0], "-", annos[1], "-", annos[2]
+	case 4:
+		mapf, cons, rf, mm, cs = annos[0], annos[1], annos[2], "-", annos[3]
+	case 5:
+		mapf, cons, rf, mm, cs = annos[0], annos[1], annos[2], annos[3],
+			annos[4]
+	default:
+		return r.lines.errorf("expected 3, 4 or 5 annotation fields but "+
+			"got %d", len(annos))
+	}
+	if h.Meta.Map {
+		if anno.Map, err = strconv.Atoi(mapf); err != nil {
+			return r.lines.errorf("could not parse MAP '%s': %s", mapf, err)
+		}
+	}
+	for _, f := range []string{cons, rf, mm, cs} {
+		if len(f) != 1 {
+			return r.lines.errorf("expected a single character annotation "+
+				"but got '%s'", f)
+		}
+	}
+	node.Residue = Residue(cons[0])
+	anno.RF, anno.MM, anno.CS = Residue(rf[0]), Residue(mm[0]), Residue(cs[0])
+	return nil
+}
+
+// readInsertTransitions reads the insertion emission line and the state
+// transition line for a single node.
+func (r *HMMERReader) readInsertTransitions(
+	alphabet Alphabet,
+	node *HMMNode,
+) error {
+	line, err := r.mustNext()
+	if err != nil {
+		return err
+	}
+	if node.InsEmit, err = r.parseEmissions(alphabet,
+		strings.Fields(line)); err != nil {
+		return err
+	}
+
+	if line, err = r.mustNext(); err != nil {
+		return err
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 7 {
+		return r.lines.errorf("expected 7 transition probabilities but "+
+			"got %d", len(fields))
+	}
+	var probs [7]Prob
+	for i, f := range fields {
+		if probs[i], err = NewProb(f); err != nil {
+			return r.lines.errorf("%s", err)
+		}
+	}
+	node.Transitions = TProbs{
+		MM: probs[0], MI: probs[1], MD: probs[2],
+		IM: probs[3], II: probs[4],
+		DM: probs[5], DD: probs[6],
+	}
+	return nil
+}
+
+func (r *HMMERReader) parseEmissions(
+	alphabet Alphabet,
+	fields []string,
+) (EProbs, error) {
+	if len(fields) != len(alphabet) {
+		return EProbs{}, r.lines.errorf("expected %d emission probabilities "+
+			"but got %d", len(alphabet), len(fields))
+	}
+	eprobs := NewEProbs(alphabet)
+	for i, f := range fields {
+		p, err := NewProb(f)
+		if err != nil {
+			return EProbs{}, r.lines.errorf("%s", err)
+		}
+		eprobs.Set(alphabet[i], p)
+	}
+	return eprobs, nil
+}
+
+func (r *HMMERReader) parseInt(tag, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, r.lines.errorf("could not parse %s '%s': %s", tag, value, err)
+	}
+	return n, nil
+}
+
+func (r *HMMERReader) parseFloat(tag, value string) (float64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, r.lines.errorf("could not parse %s '%s': %s", tag, value, err)
+	}
+	return f, nil
+}
+
+func (r *HMMERReader) parseFlag(tag, value string) (bool, error) {
+	switch value {
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	}
+	return false, r.lines.errorf("expected 'yes' or 'no' for %s but got '%s'",
+		tag, value)
+}
+
+func (r *HMMERReader) parseCutoffs(tag string, fields []string) ([]float64,
+	error) {
+	// Pfam files sometimes end cutoff lines with a ';'.
+	if len(fields) == 3 && fields[2] == ";" {
+		fields = fields[:2]
+	}
+	if len(fields) != 2 {
+		return nil, r.lines.errorf("expected 2 cutoffs for %s but got %d",
+			tag, len(fields))
+	}
+	cutoffs := make([]float64, 2)
+	for i, f := range fields {
+		var err error
+		if cutoffs[i], err = r.parseFloat(tag, strings.TrimSuffix(f, ";")); err != nil {
+			return nil, err
+		}
+	}
+	return cutoffs, nil
+}
+
+// mustNext returns the next line of input and treats the end of input as
+// an error.
+func (r *HMMERReader) mustNext() (string, error) {
+	line, err := r.lines.next()
+	if err == io.EOF {
+		return "", r.lines.errorf("unexpected end of HMMER file")
+	}
+	return line, err
+}
+
+// WriteHMMER writes each of the given HMMs in HMMER3/f format.
+//
+// Note that hmmsearch and friends require STATS lines, which can only be
+// computed by hmmbuild (or hmmcalibrate). So a HMMER value that wasn't read
+// from a calibrated file will need its Meta.Stats set for the output to be
+// accepted by HMMER.
+//
+// If Meta.Alph is empty, then it is inferred from the alphabet of the HMM,
+// which must be one of HMMER's amino, DNA or RNA alphabets. Otherwise, an
+// error is returned, since HMMER requires an ALPH line.
+func WriteHMMER(w io.Writer, hmms ...*HMMER) error {
+	buf := bufio.NewWriter(w)
+	for _, h := range hmms {
+		if err := writeHMMER(buf, h); err != nil {
+			return err
+		}
+	}
+	return buf.Flush()
+}
+
+// hmmerAlph returns the name of the HMMER alphabet (as written on the ALPH
+// line) that is the same as the alphabet given, or "" if there is none.
+func hmmerAlph(alphabet Alphabet) string {
+	switch alphabet.String() {
+	case "ACDEFGHIKLMNPQRSTVWY":
+		return "amino"
+	case "ACGT":
+		return "DNA"
+	case "ACGU":
+		return "RNA"
+	}
+	return ""
+}
+
+func writeHMMER(w *bufio.Writer, h *HMMER) error {
+	pf := func(format string, v ...interface{}) {
+		fmt.Fprintf(w, format, v...)
+	}
+	yesno := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	nodes := h.HMM.Nodes
+	alphabet := h.HMM.Alphabet
+	if len(nodes) == 0 {
+		return fmt.Errorf("cannot write HMM '%s' with no nodes", h.Meta.Name)
+	}
+	if len(h.Annotations) > 0 && len(h.Annotations) != len(nodes) {
+		return fmt.Errorf("HMM '%s' has %d nodes but %d annotations",
+			h.Meta.Name, len(nodes), len(h.Annotations))
+	}
+
+	meta := h.Meta
+	if len(meta.Alph) == 0 {
+		if meta.Alph = hmmerAlph(alphabet); len(meta.Alph) == 0 {
+			return fmt.Errorf("HMM '%s' has no ALPH and its alphabet '%s' "+
+				"is not one of HMMER's amino, DNA or RNA alphabets",
+				meta.Name, alphabet)
+		}
+	}
+
+	// Node annotations are always written in the HMMER3/f layout.
+	format := h.Meta.Format
+	if !strings.HasPrefix(format, "HMMER3/f") {
+		format = "HMMER3/f"
+	}
+	pf("%s\n", format)
+	pf("NAME  %s\n", meta.Name)
+	if len(meta.Acc) > 0 {
+		pf("ACC   %s\n", meta.Acc)
+	}
+	if len(meta.Desc) > 0 {
+		pf("DESC  %s\n", meta.Desc)
+	}
+	pf("LENG  %d\n", len(nodes)-1)
+	if meta.Maxl > 0 {
+		pf("MAXL  %d\n", meta.Maxl)
+	}
+	pf("ALPH  %s\n", meta.Alph)
+	pf("RF    %s\n", yesno(meta.RF))
+	pf("MM    %s\n", yesno(meta.MM))
+	pf("CONS  %s\n", yesno(meta.Cons))
+	pf("CS    %s\n", yesno(meta.CS))
+	pf("MAP   %s\n", yesno(meta.Map))
+	if len(meta.Date) > 0 {
+		pf("DATE  %s\n", meta.Date)
+	}
+	for _, com := range meta.Com {
+		pf("COM   %s\n", com)
+	}
+	if meta.NSeq > 0 {
+		pf("NSEQ  %d\n", meta.NSeq)
+	}
+	if meta.EffN > 0 {
+		pf("EFFN  %f\n", meta.EffN)
+	}
+	if meta.Cksum > 0 {
+		pf("CKSUM %d\n", meta.Cksum)
+	}
+	for _, cut := range []struct {
+		tag  string
+		vals []float64
+	}{{"GA", meta.GA}, {"TC", meta.TC}, {"NC", meta.NC}} {
+		if len(cut.vals) == 2 {
+			pf("%-5s %.2f %.2f\n", cut.tag, cut.vals[0], cut.vals[1])
+		}
+	}
+	for _, line := range meta.Extra {
+		pf("%s\n", line)
+	}
+	for _, s := range meta.Stats {
+		pf("STATS %s %-9s %9.4f %9.5f\n",
+			s.Mode, s.Distribution, s.Location, s.Lambda)
+	}
+
+	pf("HMM     ")
+	for _, r := range alphabet {
+		pf("     %c   ", rune(r))
+	}
+	pf("\n")
+	pf("            m->m     m->i     m->d     i->m     i->i     d->m     " +
+		"d->d\n")
+
+	emits := func(ep EProbs) {
+		for _, r := range alphabet {
+			pf(" %8s", formatHMMERProb(ep.Lookup(r)))
+		}
+	}
+	insertTrans := func(node HMMNode) {
+		pf("       ")
+		emits(node.InsEmit)
+		pf("\n       ")
+		t := node.Transitions
+		for _, p := range []Prob{t.MM, t.MI, t.MD, t.IM, t.II, t.DM, t.DD} {
+			pf(" %8s", formatHMMERProb(p))
+		}
+		pf("\n")
+	}
+
+	if len(h.Compo.Probs) > 0 {
+		pf("  COMPO")
+		emits(h.Compo)
+		pf("\n")
+	}
+	insertTrans(nodes[0])
+	for i := 1; i < len(nodes); i++ {
+		anno := HMMERAnnotation{0, '-', '-', '-'}
+		if len(h.Annotations) > 0 {
+			anno = h.Annotations[i]
+		}
+		mapf := "-"
+		if meta.Map {
+			mapf = strconv.Itoa(anno.Map)
+		}
+		cons := nodes[i].Residue
+		if cons == 0 {
+			cons = '-'
+		}
+		pf(" %6d", i)
+		emits(nodes[i].MatEmit)
+		pf(" %6s %c %c %c %c\n", mapf, rune(cons), rune(hmmerAnno(anno.RF)),
+			rune(hmmerAnno(anno.MM)), rune(hmmerAnno(anno.CS)))
+		insertTrans(nodes[i])
+	}
+	pf("//\n")
+	return nil
+}
+
+// hmmerAnno returns the residue given, or '-' if it is unset.
+func hmmerAnno(r Residue) Residue {
+	if r == 0 {
+		return '-'
+	}
+	return r
+}
+
+// formatHMMERProb formats a probability as HMMER does: five decimal places,
+// or "*" for a zero probability.
+func formatHMMERProb(p Prob) string {
+	if p.IsMin() {
+		return "*"
+	}
+	return strconv.FormatFloat(float64(p), 'f', 5, 64)
+}
@@ -0,0 +1,147 @@
+package seq
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var hmmerTest = `HMMER3/f [3.1b2 | February 2015]
+NAME  tiny
+ACC   PF99999.1
+DESC  A tiny DNA model
+LENG  3
+ALPH  DNA
+RF    no
+MM    no
+CONS  yes
+CS    no
+MAP   yes
+DATE  Tue Mar  3 12:00:00 2015
+NSEQ  4
+EFFN  2.500000
+CKSUM 1234567
+GA    20.00 20.00;
+STATS LOCAL MSV       -5.2000  0.71000
+STATS LOCAL VITERBI   -5.9000  0.71000
+STATS LOCAL FORWARD   -2.4000  0.71000
+HMM          A        C        G        T
+            m->m     m->i     m->d     i->m     i->i     d->m     d->d
+  COMPO   1.20000  1.50000  1.40000  1.45000
+          1.38629  1.38629  1.38629  1.38629
+          0.10000  3.00000  3.50000  0.60000  0.80000  0.00000        *
+      1   0.20000  2.50000  2.50000  2.50000      1 a - - -
+          1.38629  1.38629  1.38629  1.38629
+          0.10000  3.00000  3.50000  0.60000  0.80000  0.50000  0.90000
+      2   2.50000  0.20000  2.50000  2.50000      2 c - - -
+          1.38629  1.38629  1.38629  1.38629
+          0.10000  3.00000  3.50000  0.60000  0.80000  0.50000  0.90000
+      3   2.50000  2.50000  0.20000  2.50000      4 g - - -
+          1.38629  1.38629  1.38629  1.38629
+          0.00000        *        *  0.60000  0.80000  0.00000        *
+//
+`
+
+func TestReadHMMER(t *testing.T) {
+	hmms, err := ReadHMMER(strings.NewReader(hmmerTest + hmmerTest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hmms) != 2 {
+		t.Fatalf("Expected 2 HMMs but got %d.", len(hmms))
+	}
+
+	h := hmms[0]
+	if h.Meta.Name != "tiny" || h.Meta.Leng != 3 || h.Meta.NSeq != 4 {
+		t.Fatalf("Incorrect header: %#v", h.Meta)
+	}
+	if !reflect.DeepEqual(h.Meta.GA, []float64{20, 20}) {
+		t.Fatalf("Expected GA of [20 20] but got %v.", h.Meta.GA)
+	}
+	if len(h.Meta.Stats) != 3 || h.Meta.Stats[1].Location != -5.9 {
+		t.Fatalf("Incorrect STATS: %v", h.Meta.Stats)
+	}
+	if !h.HMM.Alphabet.Equals(AlphaDNA[0:4]) {
+		t.Fatalf("Expected alphabet ACGT but got %s.", h.HMM.Alphabet)
+	}
+	if len(h.HMM.Nodes) != 4 {
+		t.Fatalf("Expected 4 nodes (including begin) but got %d.",
+			len(h.HMM.Nodes))
+	}
+	if p := h.Compo.Lookup('C'); p != 1.5 {
+		t.Fatalf("Expected COMPO of C to be 1.5 but got %s.", p)
+	}
+	if p := h.HMM.Nodes[2].MatEmit.Lookup('C'); p != 0.2 {
+		t.Fatalf("Expected match emission of C to be 0.2 but got %s.", p)
+	}
+	if !h.HMM.Nodes[0].Transitions.DD.IsMin() {
+		t.Fatalf("Expected D->D of begin node to be '*'.")
+	}
+	if h.HMM.Nodes[3].Residue != 'g' || h.Annotations[3].Map != 4 {
+		t.Fatalf("Incorrect annotations for node 3.")
+	}
+
+	score := h.HMM.ViterbiScore(NewSequenceString("test", "ACG"))
+	if score.IsMin() {
+		t.Fatalf("Expected a non-minimal Viterbi score for 'ACG'.")
+	}
+}
+
+func TestWriteHMMER(t *testing.T) {
+	hmms, err := ReadHMMER(strings.NewReader(hmmerTest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := WriteHMMER(buf, hmms...); err != nil {
+		t.Fatal(err)
+	}
+	again, err := ReadHMMER(buf)
+	if err != nil {
+		t.Fatalf("Could not read written HMM: %s", err)
+	}
+	if !reflect.DeepEqual(hmms, again) {
+		t.Fatalf("HMM changed after writing and reading it again.")
+	}
+
+	// A missing ALPH is inferred from the alphabet, if possible.
+	noalph := *hmms[0]
+	noalph.Meta.Alph = ""
+	buf.Reset()
+	if err := WriteHMMER(buf, &noalph); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "\nALPH  DNA\n") {
+		t.Fatalf("Expected an inferred 'ALPH  DNA' line in\n%s", buf)
+	}
+	hmm := *noalph.HMM
+	hmm.Alphabet = NewAlphabet('A', 'C', 'G', 'X')
+	noalph.HMM = &hmm
+	if err := WriteHMMER(new(bytes.Buffer), &noalph); err == nil {
+		t.Fatalf("Expected an error writing an HMM with no ALPH and an " +
+			"unknown alphabet.")
+	}
+}
+
+func TestReadHMMERErrors(t *testing.T) {
+	tests := []struct {
+		input, line string
+	}{
+		{"HMMER2.0\n", "line 1:"},
+		{strings.Replace(hmmerTest, "LENG  3", "LENG  x", 1), "line 5:"},
+		{strings.Replace(hmmerTest, "      2   2.50000", "      5   2.50000",
+			1), "line 28:"},
+		{strings.Replace(hmmerTest, "//\n", "", 1), "line 33:"},
+	}
+	for _, test := range tests {
+		_, err := ReadHMMER(strings.NewReader(test.input))
+		if err == nil {
+			t.Fatalf("Expected an error reading\n%s", test.input)
+		}
+		if !strings.HasPrefix(err.Error(), test.line) {
+			t.Fatalf("Expected error starting with '%s' but got '%s'.",
+				test.line, err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package seq
+
+import (
+	"fmt"
+	"math"
+)
+
+// KarlinAltschul holds the parameters of the Karlin-Altschul statistics for
+// local alignment scores. They are used to convert a raw alignment score
+// into a bit score, which is comparable across scoring systems, and an
+// E-value, which is the number of alignments with at least that score
+// expected by chance.
+type KarlinAltschul struct {
+	// The scale of the scoring system, in nats per score unit.
+	Lambda float64
+
+	// The search space scaling factor.
+	K float64
+
+	// The relative entropy of the target and background frequencies, in
+	// nats per aligned pair of residues.
+	H float64
+}
+
+// NewKarlinAltschul computes the Karlin-Altschul parameters for ungapped
+// local alignment with the given substitution matrix, where residues are
+// drawn independently from the background frequencies in the null model.
+// (The null model is a frequency profile with a single column, as used by
+// FrequencyProfile.Profile.) Residues in the alphabet of the substitution
+// matrix that aren't in the null model are given a frequency of zero.
+//
+// An error is returned if the expected score of a pair of residues is not
+// negative, or if no pair of residues has a positive score, since the
+// statistics are undefined in either case.
+//
+// There is no analogous calculation for gapped alignment. Instead, use the
+// published parameters in GappedKarlinAltschul when possible.
+func NewKarlinAltschul(
+	subst SubstMatrix,
+	null *FrequencyProfile,
+) (KarlinAltschul, error) {
+	if null.Len() != 1 {
+		return KarlinAltschul{}, fmt.Errorf("null model has %d columns; "+
+			"should have 1", null.Len())
+	}
+	tot := float64(freqTotal(null.Freqs[0]))
+	if tot == 0 {
+		return KarlinAltschul{}, fmt.Errorf("null model has no residues")
+	}
+
+	// Compute the probability of each score. Only the distribution of scores
+	// matters from here on.
+	probs := make(map[int]float64)
+	lo, hi := 0, 0
+	for i, a := range subst.Alphabet {
+		pa := float64(null.Freqs[0][a]) / tot
+		for j, b := range subst.Alphabet {
+			pb := float64(null.Freqs[0][b]) / tot
+			if pa == 0 || pb == 0 {
+				continue
+			}
+			s := subst.Scores[i][j]
+			probs[s] += pa * pb
+			lo, hi = min(lo, s), max(hi, s)
+		}
+	}
+	dist := make([]float64, hi-lo+1)
+	expected, delta := 0.0, 0
+	for s, p := range probs {
+		dist[s-lo] = p
+		expected += float64(s) * p
+		if s != 0 {
+			delta = gcd(delta, s)
+		}
+	}
+	switch {
+	case hi <= 0:
+		return KarlinAltschul{}, fmt.Errorf("no pair of residues has a " +
+			"positive score")
+	case expected >= 0:
+		return KarlinAltschul{}, fmt.Errorf("the expected score %f of a "+
+			"pair of residues is not negative", expected)
+	}
+
+	lambda := karlinLambda(dist, lo)
+	h := 0.0
+	for k, p := range dist {
+		s := float64(lo + k)
+		h += lambda * s * p * math.Exp(lambda*s)
+	}
+
+	// K is computed from the infinite series given by Karlin and Altschul
+	// (1990):
+	//
+	//	sigma = sum_{k>=1} (1/k) (E[exp(lambda*S_k); S_k < 0] + P(S_k >= 0))
+	//	K = delta * lambda * exp(-2 * sigma) / (H * (1 - exp(-lambda*delta)))
+	//
+	// where S_k is the sum of k independent scores and delta is the greatest
+	// common divisor of all possible scores. The terms of the series decay
+	// exponentially, so relatively few are needed.
+	sigma := 0.0
+	sum := dist
+	for k := 1; k <= 500; k++ {
+		term := 0.0
+		for i, p := range sum {
+			if s := k*lo + i; s < 0 {
+				term += p * math.Exp(lambda*float64(s))
+			} else {
+				term += p
+			}
+		}
+		sigma += term / float64(k)
+		if term/float64(k) < 1e-12*sigma {
+			break
+		}
+		sum = convolve(sum, dist)
+	}
+	d := float64(delta)
+	K := d * lambda * math.Exp(-2*sigma) / (h * -math.Expm1(-lambda*d))
+	return KarlinAltschul{Lambda: lambda, K: K, H: h}, nil
+}
+
+// karlinLambda finds the unique positive solution of
+// sum_s P(s) * exp(lambda * s) = 1, where dist[i] is the probability of the
+// score lo + i.
+func karlinLambda(dist []float64, lo int) float64 {
+	f := func(lambda float64) float64 {
+		sum := 0.0
+		for i, p := range dist {
+			sum += p * math.Exp(lambda*float64(lo+i))
+		}
+		return sum - 1
+	}
+	left, right := 0.0, 0.5
+	for f(right) < 0 {
+		left, right = right, 2*right
+	}
+	for i := 0; i < 100 && right-left > 1e-14; i++ {
+		mid := (left + right) / 2
+		if f(mid) < 0 {
+			left = mid
+		} else {
+			right = mid
+		}
+	}
+	return (left + right) / 2
+}
+
+// convolve returns the distribution of the sum of two independent integer
+// random variables, where each distribution is given by the probabilities of
+// consecutive values.
+func convolve(a, b []float64) []float64 {
+	c := make([]float64, len(a)+len(b)-1)
+	for i, pa := range a {
+		if pa == 0 {
+			continue
+		}
+		for j, pb := range b {
+			c[i+j] += pa * pb
+		}
+	}
+	return c
+}
+
+func gcd(a, b int) int {
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// BitScore converts a raw alignment score to a bit score, which is
+// (lambda*score - ln K) / ln 2.
+func (ka KarlinAltschul) BitScore(score int) float64 {
+	return (ka.Lambda*float64(score) - math.Log(ka.K)) / math.Ln2
+}
+
+// EValue returns the number of alignments with at least the given raw score
+// that are expected by chance when comparing a query of length m against a
+// database with a total length of n residues. This is K*m*n*exp(-lambda*S).
+//
+// No correction is made for edge effects, so very short sequences will have
+// E-values that are somewhat too large.
+func (ka KarlinAltschul) EValue(score int, m, n int) float64 {
+	return ka.K * float64(m) * float64(n) * math.Exp(-ka.Lambda*float64(score))
+}
+
+// gappedKey identifies a substitution matrix and gap costs.
+type gappedKey struct {
+	name            string
+	open, extension int
+}
+
+// The published Karlin-Altschul parameters for gapped alignment, which were
+// estimated by NCBI BLAST from random sequence simulations.
+var gappedParams = map[gappedKey]KarlinAltschul{
+	{"BLOSUM45", 14, 2}: {0.195, 0.032, 0.10},
+	{"BLOSUM50", 13, 2}: {0.193, 0.035, 0.12},
+	{"BLOSUM62", 12, 1}: {0.283, 0.059, 0.19},
+	{"BLOSUM62", 11, 1}: {0.267, 0.041, 0.14},
+	{"BLOSUM62", 10, 1}: {0.243, 0.024, 0.10},
+	{"BLOSUM80", 10, 1}: {0.299, 0.071, 0.27},
+	{"BLOSUM90", 10, 1}: {0.309, 0.088, 0.31},
+	{"PAM30", 9, 1}:     {0.294, 0.11, 0.61},
+	{"PAM70", 10, 1}:    {0.270, 0.060, 0.39},
+	{"PAM250", 14, 2}:   {0.182, 0.024, 0.073},
+}
+
+// GappedKarlinAltschul returns the published parameters for gapped local
+// alignment with one of the built in substitution matrices, identified by
+// its name in SubstMatrices, and the given gap costs (with the same meaning
+// as in AlignConfig). If no parameters are known for the combination, then
+// false is returned.
+//
+// Parameters are tabulated for the default gap costs of BLAST for each
+// matrix: BLOSUM45 with 14/2, BLOSUM50 with 13/2, BLOSUM62 with 11/1,
+// BLOSUM80 and BLOSUM90 with 10/1, PAM30 with 9/1, PAM70 with 10/1 and PAM250
+// with 14/2. BLOSUM62 also has parameters for gap costs of 12/1 and 10/1.
+func GappedKarlinAltschul(
+	name string,
+	gapOpen, gapExtend int,
+) (KarlinAltschul, bool) {
+	ka, ok := gappedParams[gappedKey{name, gapOpen, gapExtend}]
+	return ka, ok
+}
@@ -0,0 +1,111 @@
+package seq
+
+import (
+	"math"
+	"testing"
+)
+
+// robinsonNull returns a null model with the amino acid background
+// frequencies of Robinson and Robinson (1991), as used by BLAST.
+func robinsonNull() *FrequencyProfile {
+	freqs := map[Residue]int{
+		'A': 7805, 'R': 5129, 'N': 4487, 'D': 5364, 'C': 1925,
+		'Q': 4264, 'E': 6295, 'G': 7377, 'H': 2199, 'I': 5142,
+		'L': 9019, 'K': 5744, 'M': 2243, 'F': 3856, 'P': 5203,
+		'S': 7120, 'T': 5841, 'W': 1330, 'Y': 3216, 'V': 6441,
+	}
+	null := NewNullProfile()
+	for r, f := range freqs {
+		null.Freqs[0][r] = f
+	}
+	return null
+}
+
+func TestKarlinAltschul(t *testing.T) {
+	// The published ungapped parameters for BLOSUM62.
+	ka, err := NewKarlinAltschul(SubstBlosum62, robinsonNull())
+	if err != nil {
+		t.Fatal(err)
+	}
+	near := func(name string, got, want, tol float64) {
+		if math.Abs(got-want) > tol {
+			t.Fatalf("Expected %s = %f but got %f.", name, want, got)
+		}
+	}
+	near("lambda", ka.Lambda, 0.3176, 0.001)
+	near("K", ka.K, 0.134, 0.002)
+	near("H", ka.H, 0.4012, 0.002)
+
+	// A matrix with an even span of scores.
+	even := SubstMatrix{
+		NewAlphabet('A', 'C'),
+		[][]int{{2, -4}, {-4, 2}},
+	}
+	null := NewFrequencyProfileAlphabet(1, even.Alphabet)
+	null.Freqs[0]['A'], null.Freqs[0]['C'] = 1, 1
+	ka, err = NewKarlinAltschul(even, null)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Solve 0.5*exp(2x) + 0.5*exp(-4x) = 1 analytically: with y = exp(2x),
+	// y^3 - 2y^2 + 1 = 0, so y = (1 + sqrt(5)) / 2.
+	near("lambda", ka.Lambda, math.Log((1+math.Sqrt(5))/2)/2, 1e-9)
+
+	// The expected score must be negative.
+	positive := SubstMatrix{NewAlphabet('A', 'C'), [][]int{{2, 1}, {1, 2}}}
+	if _, err := NewKarlinAltschul(positive, null); err == nil {
+		t.Fatalf("Expected an error for a matrix with positive scores.")
+	}
+}
+
+func TestKarlinAltschulScores(t *testing.T) {
+	ka, ok := GappedKarlinAltschul("BLOSUM62", 11, 1)
+	if !ok {
+		t.Fatalf("No parameters for BLOSUM62 with gap costs 11/1.")
+	}
+	if _, ok := GappedKarlinAltschul("BLOSUM62", 1, 1); ok {
+		t.Fatalf("Unexpected parameters for BLOSUM62 with gap costs 1/1.")
+	}
+
+	// (0.267*100 - ln 0.041) / ln 2 is about 43.1.
+	bits := ka.BitScore(100)
+	if math.Abs(bits-43.1) > 0.05 {
+		t.Fatalf("Expected a bit score of 43.1 but got %f.", bits)
+	}
+	e := ka.EValue(100, 250, 1000000)
+	want := 250 * 1000000 * math.Pow(2, -bits)
+	if math.Abs(e-want)/want > 1e-9 {
+		t.Fatalf("Expected an E-value of %g but got %g.", want, e)
+	}
+}
+
+func TestGappedKarlinAltschul(t *testing.T) {
+	tests := []struct {
+		name      string
+		open, ext int
+		ka        KarlinAltschul
+	}{
+		{"BLOSUM45", 14, 2, KarlinAltschul{0.195, 0.032, 0.10}},
+		{"BLOSUM50", 13, 2, KarlinAltschul{0.193, 0.035, 0.12}},
+		{"BLOSUM62", 11, 1, KarlinAltschul{0.267, 0.041, 0.14}},
+		{"BLOSUM80", 10, 1, KarlinAltschul{0.299, 0.071, 0.27}},
+		{"BLOSUM90", 10, 1, KarlinAltschul{0.309, 0.088, 0.31}},
+		{"PAM30", 9, 1, KarlinAltschul{0.294, 0.11, 0.61}},
+		{"PAM70", 10, 1, KarlinAltschul{0.270, 0.060, 0.39}},
+		{"PAM250", 14, 2, KarlinAltschul{0.182, 0.024, 0.073}},
+	}
+	for _, test := range tests {
+		if _, ok := SubstMatrices[test.name]; !ok {
+			t.Fatalf("No built in matrix named %s.", test.name)
+		}
+		ka, ok := GappedKarlinAltschul(test.name, test.open, test.ext)
+		if !ok {
+			t.Fatalf("No parameters for %s with gap costs %d/%d.",
+				test.name, test.open, test.ext)
+		}
+		if ka != test.ka {
+			t.Fatalf("Expected parameters %+v for %s but got %+v.",
+				test.ka, test.name, ka)
+		}
+	}
+}
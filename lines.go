@@ -0,0 +1,66 @@
+package seq
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// lineReader reads an input one line at a time while keeping track of line
+// numbers, so that parse errors can say where they occurred.
+type lineReader struct {
+	buf  *bufio.Reader
+	line int
+
+	// A line that was pushed back with unread.
+	pending *string
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{buf: bufio.NewReader(r)}
+}
+
+// next returns the next line of input with trailing whitespace (including
+// any '\r') removed. At the end of the input, io.EOF is returned.
+func (r *lineReader) next() (string, error) {
+	if r.pending != nil {
+		line := *r.pending
+		r.pending = nil
+		r.line++
+		return line, nil
+	}
+	line, err := r.buf.ReadString('\n')
+	if err == io.EOF && len(line) == 0 {
+		return "", io.EOF
+	} else if err != nil && err != io.EOF {
+		return "", fmt.Errorf("line %d: %s", r.line+1, err)
+	}
+	r.line++
+	return strings.TrimRight(line, " \t\r\n"), nil
+}
+
+// nextNonBlank is like next, except blank lines are skipped.
+func (r *lineReader) nextNonBlank() (string, error) {
+	for {
+		line, err := r.next()
+		if err != nil {
+			return "", err
+		}
+		if len(strings.TrimSpace(line)) > 0 {
+			return line, nil
+		}
+	}
+}
+
+// unread pushes a line back so that it is returned by the next call to next.
+// Only one line may be pushed back at a time.
+func (r *lineReader) unread(line string) {
+	r.pending = &line
+	r.line--
+}
+
+// errorf returns an error prefixed with the current line number.
+func (r *lineReader) errorf(format string, v ...interface{}) error {
+	return fmt.Errorf("line %d: %s", r.line, fmt.Sprintf(format, v...))
+}
@@ -189,6 +189,14 @@ func (m *MSA) Add(adds Sequence) {
 			// This is a match/delete column, so we're good.
 		}
 	}
+
+	// Any residues left over are inserts after the last column, so the rest
+	// of the sequences need inserts there too.
+	for ; m.length < s.Len(); m.length++ {
+		for i := range m.Entries {
+			m.Entries[i].Residues = append(m.Entries[i].Residues, '.')
+		}
+	}
 	m.Entries = append(m.Entries, s)
 }
 
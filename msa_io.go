@@ -0,0 +1,113 @@
+package seq
+
+import (
+	"fmt"
+	"io"
+)
+
+// ReadA2M reads a multiple sequence alignment in A2M format. Every sequence
+// in the input must have the same length.
+func ReadA2M(r io.Reader) (MSA, error) {
+	seqs, err := NewFastaReader(r).ReadAll()
+	if err != nil {
+		return MSA{}, err
+	}
+	for _, s := range seqs {
+		if s.Len() != seqs[0].Len() {
+			return MSA{}, fmt.Errorf("A2M sequences must all be the same "+
+				"length, but '%s' has length %d and '%s' has length %d.",
+				seqs[0].Name, seqs[0].Len(), s.Name, s.Len())
+		}
+	}
+	msa := NewMSA()
+	msa.AddSlice(seqs)
+	return msa, nil
+}
+
+// ReadA3M reads a multiple sequence alignment in A3M format. Insertion
+// columns are detected automatically from the lower case residues in each
+// sequence, and the resulting MSA is represented in A2M format.
+//
+// Since A2M formatted sequences are also valid A3M sequences, ReadA3M can
+// read either format. Every sequence must have the same number of match and
+// deletion columns (upper case residues and '-' characters).
+func ReadA3M(r io.Reader) (MSA, error) {
+	fr := NewFastaReader(r)
+	var seqs []Sequence
+	for {
+		s, err := fr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return MSA{}, err
+		}
+		seqs = append(seqs, s)
+		if n, first := a3mColumns(s), a3mColumns(seqs[0]); n != first {
+			return MSA{}, fmt.Errorf("line %d: A3M sequences must all have "+
+				"the same number of match and deletion columns, but '%s' "+
+				"has %d and '%s' has %d", fr.lastLine, seqs[0].Name, first,
+				s.Name, n)
+		}
+	}
+	msa := NewMSA()
+	msa.AddSlice(seqs)
+	return msa, nil
+}
+
+// a3mColumns returns the number of match and deletion columns in an A3M
+// sequence.
+func a3mColumns(s Sequence) int {
+	n := 0
+	for _, r := range s.Residues {
+		if r == '-' || (r >= 'A' && r <= 'Z') {
+			n++
+		}
+	}
+	return n
+}
+
+// ReadAlignedFasta reads a multiple sequence alignment in aligned FASTA
+// format. Every sequence in the input must have the same length.
+func ReadAlignedFasta(r io.Reader) (MSA, error) {
+	seqs, err := NewFastaReader(r).ReadAll()
+	if err != nil {
+		return MSA{}, err
+	}
+	for _, s := range seqs {
+		if s.Len() != seqs[0].Len() {
+			return MSA{}, fmt.Errorf("FASTA aligned sequences must all be "+
+				"the same length, but '%s' has length %d and '%s' has "+
+				"length %d.", seqs[0].Name, seqs[0].Len(), s.Name, s.Len())
+		}
+	}
+	msa := NewMSA()
+	msa.AddFastaSlice(seqs)
+	return msa, nil
+}
+
+// WriteA2M writes the MSA in A2M format, with each sequence on a single line.
+func WriteA2M(w io.Writer, msa MSA) error {
+	return writeMSA(w, msa, msa.GetA2M)
+}
+
+// WriteA3M writes the MSA in A3M format, with each sequence on a single line.
+func WriteA3M(w io.Writer, msa MSA) error {
+	return writeMSA(w, msa, msa.GetA3M)
+}
+
+// WriteAlignedFasta writes the MSA in aligned FASTA format, with each
+// sequence on a single line.
+func WriteAlignedFasta(w io.Writer, msa MSA) error {
+	return writeMSA(w, msa, msa.GetFasta)
+}
+
+func writeMSA(w io.Writer, msa MSA, get func(row int) Sequence) error {
+	fw := NewFastaWriter(w)
+	fw.Columns = 0
+	for row := range msa.Entries {
+		if err := fw.Write(get(row)); err != nil {
+			return err
+		}
+	}
+	return fw.Flush()
+}
@@ -0,0 +1,91 @@
+package seq
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadMSA(t *testing.T) {
+	tests := []struct {
+		read          func(r io.Reader) (MSA, error)
+		input, answer []string
+	}{
+		{ReadA2M, alignA2M, alignA2M},
+		{ReadA3M, alignA3M, alignA2M},
+		{ReadA3M, alignA2M, alignA2M},
+		{ReadAlignedFasta, alignFasta, alignA2M},
+
+		// Inserts after the last match column of an earlier sequence.
+		{ReadA3M, []string{"AB", "ABcd"}, []string{"AB..", "ABcd"}},
+	}
+	for _, test := range tests {
+		computed, err := test.read(strings.NewReader(fastaString(test.input)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testEqualAlign(t, computed, makeMSA(makeSeqs(test.answer)))
+	}
+}
+
+func TestReadMSAErrors(t *testing.T) {
+	input := ">0\nABCD\n>1\nABC\n"
+	if _, err := ReadA2M(strings.NewReader(input)); err == nil {
+		t.Fatalf("Expected an error reading A2M with different lengths.")
+	}
+	if _, err := ReadAlignedFasta(strings.NewReader(input)); err == nil {
+		t.Fatalf("Expected an error reading FASTA with different lengths.")
+	}
+
+	// A3M rows must have the same number of match and deletion columns,
+	// whether they are longer or shorter than the first row.
+	tests := []struct {
+		input, err string
+	}{
+		{">0\nABC\n>1\nABCDE\n", "line 3:"},
+		{">0\nAB-D\n>1\nAb-CdD\n>2\nABcdef\n>3\nA-CD\n", "line 5:"},
+	}
+	for _, test := range tests {
+		_, err := ReadA3M(strings.NewReader(test.input))
+		if err == nil {
+			t.Fatalf("Expected an error reading A3M\n%s", test.input)
+		}
+		if !strings.HasPrefix(err.Error(), test.err) {
+			t.Fatalf("Expected error starting with '%s' but got '%s'.",
+				test.err, err)
+		}
+	}
+}
+
+func TestWriteMSA(t *testing.T) {
+	tests := []struct {
+		write  func(w io.Writer, msa MSA) error
+		answer []string
+	}{
+		{WriteA2M, alignA2M},
+		{WriteA3M, alignA3M},
+		{WriteAlignedFasta, alignFasta},
+	}
+	msa := makeMSA(makeSeqs(alignA2M))
+	for _, test := range tests {
+		buf := new(bytes.Buffer)
+		if err := test.write(buf, msa); err != nil {
+			t.Fatal(err)
+		}
+		if answer := fastaString(test.answer); buf.String() != answer {
+			t.Fatalf("Expected\n%s\nbut got\n%s", answer, buf.String())
+		}
+	}
+}
+
+// fastaString formats each string as a FASTA record named by its index,
+// which mirrors the names used by makeSeqs.
+func fastaString(strs []string) string {
+	buf := new(bytes.Buffer)
+	for i, str := range strs {
+		fmt.Fprintf(buf, ">%d\n%s\n", i, str)
+	}
+	return buf.String()
+}
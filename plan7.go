@@ -0,0 +1,470 @@
+package seq
+
+import (
+	"fmt"
+	"math"
+)
+
+// Plan7Mode specifies how a Plan7Profile aligns domains to the model.
+type Plan7Mode int
+
+const (
+	// Plan7Local allows any number of domains, each of which may align to
+	// any fragment of the model. This is HMMER's default search mode.
+	Plan7Local Plan7Mode = iota
+
+	// Plan7Glocal allows any number of domains, each of which must align to
+	// the entire model.
+	Plan7Glocal
+
+	// Plan7UniLocal is like Plan7Local, but with exactly one domain.
+	Plan7UniLocal
+
+	// Plan7UniGlocal is like Plan7Glocal, but with exactly one domain.
+	Plan7UniGlocal
+)
+
+func (mode Plan7Mode) local() bool {
+	return mode == Plan7Local || mode == Plan7UniLocal
+}
+
+func (mode Plan7Mode) multiHit() bool {
+	return mode == Plan7Local || mode == Plan7Glocal
+}
+
+// Plan7Profile is a search profile built from an HMM, in the style of
+// HMMER3. The core model (the match, insertion and deletion states of the
+// HMM) is wrapped by the special states of the Plan7 architecture:
+//
+//	S -> N -> B -> core model -> E -> C -> T
+//	                   ^          |
+//	                   +--- J <---+
+//
+// N, C and J emit residues before, after and between domains, with the
+// background frequencies of the null model. In local modes, a domain may
+// enter the core model at any match state (weighted by how often each match
+// state is used) and leave it from any match or deletion state. In glocal
+// modes, a domain must align to every node. The transitions of N, C and J
+// depend on the length of the sequence, so that the expected length of the
+// flanking regions matches the sequence.
+//
+// The insertion states of the begin node and the last node aren't used,
+// since N, C and J emit those residues instead.
+//
+// Scores are negative log-odds scores (as Probs, in nats) of the sequence
+// according to the profile relative to the null model, so that a score
+// below zero means the sequence is more likely to contain a domain than to
+// be random. The null model emits residues with the background frequencies
+// of the HMM and has a geometric length distribution with a mean of the
+// length of the sequence, as in HMMER.
+type Plan7Profile struct {
+	HMM  *HMM
+	Mode Plan7Mode
+
+	// The number of match states in the model.
+	m int
+
+	// The emission log-odds scores of the match and insertion states, and
+	// the scores of entering the core model at each match state. All are
+	// indexed by node.
+	mat, ins []EProbs
+	entry    []Prob
+}
+
+// NewPlan7Profile builds a search profile from the given HMM. The
+// background frequencies are the null emissions of the HMM, or the insertion
+// emissions of the begin node if the HMM has no null emissions. (This is the
+// same convention used when writing hhm files.)
+//
+// NewPlan7Profile panics if the HMM has no match states.
+func NewPlan7Profile(hmm *HMM, mode Plan7Mode) *Plan7Profile {
+	M := len(hmm.Nodes) - 1
+	if M < 1 {
+		panic(fmt.Sprintf("Cannot build a Plan7 profile from an HMM with "+
+			"%d match states.", max(0, M)))
+	}
+	null := hmm.Null
+	if len(null.Probs) == 0 {
+		null = hmm.Nodes[0].InsEmit
+	}
+
+	p := &Plan7Profile{
+		HMM:   hmm,
+		Mode:  mode,
+		m:     M,
+		mat:   make([]EProbs, M+1),
+		ins:   make([]EProbs, M+1),
+		entry: make([]Prob, M+1),
+	}
+	odds := func(emits EProbs) EProbs {
+		ep := NewEProbs(hmm.Alphabet)
+		for _, r := range hmm.Alphabet {
+			e, q := emits.Lookup(r), null.Lookup(r)
+			if !e.IsMin() && !q.IsMin() {
+				ep.Set(r, e-q)
+			}
+		}
+		return ep
+	}
+	for k := 1; k <= M; k++ {
+		p.mat[k] = odds(hmm.Nodes[k].MatEmit)
+		p.ins[k] = odds(hmm.Nodes[k].InsEmit)
+	}
+
+	// Transition probabilities are needed (rather than Probs) to compute
+	// the entry scores.
+	t := func(k int) (mm, mi, md, im, dm, dd float64) {
+		tr := hmm.Nodes[k].Transitions
+		return tr.MM.Ratio(), tr.MI.Ratio(), tr.MD.Ratio(), tr.IM.Ratio(),
+			tr.DM.Ratio(), tr.DD.Ratio()
+	}
+	entry := make([]float64, M+1)
+	if mode.local() {
+		// Entry is weighted by the probability that each match state is
+		// used (its occupancy), so that every fragment of the model is
+		// about equally likely, as in HMMER.
+		mm, mi, _, _, _, _ := t(0)
+		occ := mm + mi
+		z := 0.0
+		for k := 1; k <= M; k++ {
+			if k > 1 {
+				mm, mi, _, _, dm, _ := t(k - 1)
+				occ = occ*(mm+mi) + (1-occ)*dm
+			}
+			entry[k] = occ
+			z += occ * float64(M-k+1)
+		}
+		for k := 1; k <= M; k++ {
+			entry[k] /= z
+		}
+	} else {
+		// A glocal domain that starts by deleting nodes 1 to k-1 enters at
+		// match state k. (These are the only paths through the deletion
+		// states of the first nodes.)
+		mm, _, md, _, _, _ := t(0)
+		entry[1] = mm / (mm + md)
+		deleted := md / (mm + md)
+		for k := 2; k <= M; k++ {
+			_, _, _, _, dm, dd := t(k - 1)
+			entry[k] = deleted * dm
+			deleted *= dd
+		}
+	}
+	for k := 1; k <= M; k++ {
+		p.entry[k] = probFromRatio(entry[k])
+	}
+	return p
+}
+
+// probFromRatio converts a probability in the range [0, 1] to a Prob.
+func probFromRatio(ratio float64) Prob {
+	if ratio <= 0 {
+		return MinProb
+	}
+	return -Prob(math.Log(ratio))
+}
+
+// plan7Specials are the transition scores of the special states for a
+// particular sequence length.
+type plan7Specials struct {
+	loop, move, ec, ej Prob
+
+	// The score of the transitions of the null model.
+	null Prob
+}
+
+// specials computes the scores of the special transitions for a sequence of
+// length L, as HMMER does.
+func (p *Plan7Profile) specials(L int) plan7Specials {
+	var s plan7Specials
+	nj := 0.0
+	if p.Mode.multiHit() {
+		nj = 1
+		s.ec, s.ej = probFromRatio(0.5), probFromRatio(0.5)
+	} else {
+		s.ec, s.ej = 0, MinProb
+	}
+	fl := float64(L)
+	s.move = probFromRatio((2 + nj) / (fl + 2 + nj))
+	s.loop = probFromRatio(fl / (fl + 2 + nj))
+	s.null = probFromRatio(1/(fl+1)) + Prob(fl)*probFromRatio(fl/(fl+1))
+	return s
+}
+
+// plan7Table is a dynamic programming table for a Plan7Profile. The core
+// states are indexed by row (the number of residues emitted) and node, and
+// the special states are indexed by row.
+type plan7Table struct {
+	m, ins, del   []Prob
+	n, b, e, c, j []Prob
+	nodes         int
+}
+
+func (t *plan7Table) index(i, k int) int {
+	return i*t.nodes + k
+}
+
+// plan7Cand is a candidate predecessor of a state, along with the score of
+// arriving from it.
+type plan7Cand struct {
+	p     Prob
+	state HMMState
+	node  int
+}
+
+// matchCands returns the ways of arriving at the match state of node k
+// before emitting residue i-1.
+func (p *Plan7Profile) matchCands(t *plan7Table, i, k int) [4]plan7Cand {
+	cands := [4]plan7Cand{
+		{t.b[i-1] + p.entry[k], Begin, 0},
+		{MinProb, Match, k - 1},
+		{MinProb, Insertion, k - 1},
+		{MinProb, Deletion, k - 1},
+	}
+	if k > 1 {
+		tr := p.HMM.Nodes[k-1].Transitions
+		prev := t.index(i-1, k-1)
+		cands[1].p = t.m[prev] + tr.MM
+		cands[2].p = t.ins[prev] + tr.IM
+		cands[3].p = t.del[prev] + tr.DM
+	}
+	return cands
+}
+
+// insertionCands is like matchCands, but for the insertion state.
+func (p *Plan7Profile) insertionCands(t *plan7Table, i, k int) [4]plan7Cand {
+	tr := p.HMM.Nodes[k].Transitions
+	prev := t.index(i-1, k)
+	return [4]plan7Cand{
+		{t.m[prev] + tr.MI, Match, k},
+		{t.ins[prev] + tr.II, Insertion, k},
+		{MinProb, Match, k},
+		{MinProb, Match, k},
+	}
+}
+
+// deletionCands is like matchCands, but for the deletion state, which
+// doesn't emit a residue. There are no deletion states before the first
+// match state (see the entry scores).
+func (p *Plan7Profile) deletionCands(t *plan7Table, i, k int) [4]plan7Cand {
+	tr := p.HMM.Nodes[k-1].Transitions
+	prev := t.index(i, k-1)
+	return [4]plan7Cand{
+		{t.m[prev] + tr.MD, Match, k - 1},
+		{t.del[prev] + tr.DD, Deletion, k - 1},
+		{MinProb, Match, k},
+		{MinProb, Match, k},
+	}
+}
+
+// endCands returns the ways of arriving at the end state of row i. In local
+// modes, a domain may end at any match or deletion state.
+func (p *Plan7Profile) endCands(t *plan7Table, i int) []plan7Cand {
+	first := p.m
+	if p.Mode.local() {
+		first = 1
+	}
+	cands := make([]plan7Cand, 0, 2*(p.m-first+1))
+	for k := first; k <= p.m; k++ {
+		cands = append(cands,
+			plan7Cand{t.m[t.index(i, k)], Match, k},
+			plan7Cand{t.del[t.index(i, k)], Deletion, k})
+	}
+	return cands
+}
+
+// fill computes the table for the sequence, combining the scores of
+// alternative paths with combine, and returns the score of the sequence
+// (before accounting for the null model).
+func (p *Plan7Profile) fill(
+	seq Sequence,
+	combine func(p1, p2 Prob) Prob,
+) (*plan7Table, Prob) {
+	L, M := seq.Len(), p.m
+	sp := p.specials(L)
+	t := &plan7Table{nodes: M + 1}
+	t.m, t.ins, t.del = make([]Prob, (L+1)*(M+1)),
+		make([]Prob, (L+1)*(M+1)), make([]Prob, (L+1)*(M+1))
+	t.n, t.b, t.e = make([]Prob, L+1), make([]Prob, L+1), make([]Prob, L+1)
+	t.c, t.j = make([]Prob, L+1), make([]Prob, L+1)
+	for _, row := range [][]Prob{t.m, t.ins, t.del, t.n, t.b, t.e, t.c, t.j} {
+		for i := range row {
+			row[i] = MinProb
+		}
+	}
+	fold := func(cands []plan7Cand) Prob {
+		total := MinProb
+		for _, cand := range cands {
+			total = combine(total, cand.p)
+		}
+		return total
+	}
+
+	for i := 0; i <= L; i++ {
+		if i > 0 {
+			r := seq.Residues[i-1]
+			for k := 1; k <= M; k++ {
+				here := t.index(i, k)
+				cands := p.matchCands(t, i, k)
+				t.m[here] = fold(cands[:]) + p.mat[k].Lookup(r)
+				if k < M {
+					cands = p.insertionCands(t, i, k)
+					t.ins[here] = fold(cands[:]) + p.ins[k].Lookup(r)
+				}
+			}
+		}
+		for k := 2; k <= M; k++ {
+			cands := p.deletionCands(t, i, k)
+			t.del[t.index(i, k)] = fold(cands[:])
+		}
+		t.e[i] = fold(p.endCands(t, i))
+
+		if i == 0 {
+			t.n[i] = 0
+		} else {
+			t.n[i] = t.n[i-1] + sp.loop
+			t.j[i] = t.j[i-1] + sp.loop
+			t.c[i] = t.c[i-1] + sp.loop
+		}
+		t.j[i] = combine(t.j[i], t.e[i]+sp.ej)
+		t.c[i] = combine(t.c[i], t.e[i]+sp.ec)
+		t.b[i] = combine(t.n[i]+sp.move, t.j[i]+sp.move)
+	}
+	return t, t.c[L] + sp.move
+}
+
+// score converts the score of the sequence computed by fill to a log-odds
+// score relative to the null model.
+func (p *Plan7Profile) score(L int, total Prob) Prob {
+	if total >= MinProb {
+		return MinProb
+	}
+	return total - p.specials(L).null
+}
+
+// Forward returns the log-odds score of the sequence according to the
+// profile, summed over every path through the profile.
+//
+// If there is no path through the profile for the sequence, then the
+// minimum probability is returned.
+func (p *Plan7Profile) Forward(seq Sequence) Prob {
+	_, total := p.fill(seq, probSum)
+	return p.score(seq.Len(), total)
+}
+
+// Viterbi returns the log-odds score of the likeliest path through the
+// profile for the sequence, along with the path itself.
+//
+// The path includes the special states: residues emitted by N, C and J are
+// steps with the NTerminal, CTerminal and Joining states, and each domain
+// starts with a Begin step and finishes with an End step (neither of which
+// emit a residue, so their Obs is -1). Use HMMPath.Domains to get the path
+// of each domain.
+//
+// If there is no path through the profile for the sequence, then the
+// minimum probability and a nil path are returned.
+func (p *Plan7Profile) Viterbi(seq Sequence) (Prob, HMMPath) {
+	t, total := p.fill(seq, probMin)
+	if total >= MinProb {
+		return MinProb, nil
+	}
+	L := seq.Len()
+	sp := p.specials(L)
+
+	// Find the predecessor of the current state by recomputing the
+	// candidates and choosing the first with the best score.
+	best := func(cands []plan7Cand) plan7Cand {
+		b := cands[0]
+		for _, cand := range cands[1:] {
+			if cand.p < b.p {
+				b = cand
+			}
+		}
+		return b
+	}
+
+	var path HMMPath
+	state, node, i := CTerminal, 0, L
+	for !(state == NTerminal && i == 0) {
+		switch state {
+		case CTerminal, Joining:
+			loop := t.c
+			if state == Joining {
+				loop = t.j
+			}
+			if i > 0 && loop[i] == loop[i-1]+sp.loop {
+				path = append(path, HMMStep{state, 0, i - 1})
+				i--
+			} else {
+				state = End
+			}
+		case NTerminal:
+			path = append(path, HMMStep{NTerminal, 0, i - 1})
+			i--
+		case End:
+			path = append(path, HMMStep{End, 0, -1})
+			b := best(p.endCands(t, i))
+			state, node = b.state, b.node
+		case Begin:
+			path = append(path, HMMStep{Begin, 0, -1})
+			if t.b[i] == t.n[i]+sp.move {
+				state = NTerminal
+			} else {
+				state = Joining
+			}
+		case Match:
+			path = append(path, HMMStep{Match, node, i - 1})
+			cands := p.matchCands(t, i, node)
+			b := best(cands[:])
+			if b.state == Begin && !p.Mode.local() {
+				// The deletions folded into the glocal entry score.
+				for k := node - 1; k >= 1; k-- {
+					path = append(path, HMMStep{Deletion, k, -1})
+				}
+			}
+			state, node, i = b.state, b.node, i-1
+		case Insertion:
+			path = append(path, HMMStep{Insertion, node, i - 1})
+			cands := p.insertionCands(t, i, node)
+			b := best(cands[:])
+			state, node, i = b.state, b.node, i-1
+		case Deletion:
+			path = append(path, HMMStep{Deletion, node, -1})
+			cands := p.deletionCands(t, i, node)
+			b := best(cands[:])
+			state, node = b.state, b.node
+		}
+	}
+	for a, b := 0, len(path)-1; a < b; a, b = a+1, b-1 {
+		path[a], path[b] = path[b], path[a]
+	}
+	return p.score(L, total), path
+}
+
+// probMin returns the likelier of two probabilities.
+func probMin(p1, p2 Prob) Prob {
+	if p1 < p2 {
+		return p1
+	}
+	return p2
+}
+
+// Domains splits a path through a Plan7Profile into the paths through the
+// core model of each domain, without the Begin and End steps. Each domain
+// can be rendered with A2M.
+func (path HMMPath) Domains() []HMMPath {
+	var domains []HMMPath
+	start := -1
+	for s, step := range path {
+		switch step.State {
+		case Begin:
+			start = s + 1
+		case End:
+			if start >= 0 {
+				domains = append(domains, path[start:s])
+			}
+			start = -1
+		}
+	}
+	return domains
+}
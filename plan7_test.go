@@ -0,0 +1,130 @@
+package seq
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// consensusHMM returns a DNA HMM that strongly prefers the consensus given.
+func consensusHMM(consensus string) *HMM {
+	p := func(ratio float64) Prob { return -Prob(math.Log(ratio)) }
+	alphabet := AlphaDNA[0:4]
+	uniform := NewEProbs(alphabet)
+	for _, r := range alphabet {
+		uniform.Set(r, p(0.25))
+	}
+	trans := TProbs{
+		MM: p(0.9), MI: p(0.05), MD: p(0.05),
+		IM: p(0.5), II: p(0.5), DM: p(0.5), DD: p(0.5),
+	}
+	nodes := []HMMNode{{NodeNum: 0, InsEmit: uniform, Transitions: trans}}
+	nodes[0].Transitions.DM, nodes[0].Transitions.DD = MinProb, MinProb
+	for i := range consensus {
+		node := HMMNode{
+			Residue:     Residue(consensus[i]),
+			NodeNum:     i + 1,
+			InsEmit:     uniform,
+			MatEmit:     NewEProbs(alphabet),
+			Transitions: trans,
+		}
+		for _, r := range alphabet {
+			node.MatEmit.Set(r, p(0.03))
+		}
+		node.MatEmit.Set(Residue(consensus[i]), p(0.91))
+		nodes = append(nodes, node)
+	}
+	last := &nodes[len(nodes)-1].Transitions
+	*last = TProbs{MM: 0, MI: MinProb, MD: MinProb,
+		IM: 0, II: MinProb, DM: 0, DD: MinProb}
+	return NewHMM(nodes, alphabet, EProbs{})
+}
+
+func TestPlan7Profile(t *testing.T) {
+	hmm := consensusHMM("ACGTTGCAAC")
+
+	domainA2M := func(p *Plan7Profile, s string) []string {
+		seq := NewSequenceString(s, s)
+		score, path := p.Viterbi(seq)
+		if score.IsMin() {
+			t.Fatalf("Expected a path for '%s'.", s)
+		}
+		if forward := p.Forward(seq); forward.Less(score) {
+			t.Fatalf("Viterbi score %s is better than forward score %s "+
+				"for '%s'.", score, forward, s)
+		}
+		emitted := 0
+		for _, step := range path {
+			if step.Obs >= 0 {
+				if step.Obs != emitted {
+					t.Fatalf("Expected step %s to emit residue %d in %v.",
+						step, emitted, path)
+				}
+				emitted++
+			}
+		}
+		if emitted != seq.Len() {
+			t.Fatalf("Expected every residue of '%s' to be emitted by %v.",
+				s, path)
+		}
+
+		var a2ms []string
+		for _, domain := range path.Domains() {
+			a2ms = append(a2ms, string(domain.A2M(hmm, seq).Bytes()))
+		}
+		return a2ms
+	}
+
+	tests := []struct {
+		mode    Plan7Mode
+		seq     string
+		domains []string
+	}{
+		{Plan7Local, "GGGGACGTTGCAACGGGG", []string{"ACGTTGCAAC"}},
+		{Plan7Local, "GGACGTTGCAACGGGGACGTTGCAACGG",
+			[]string{"ACGTTGCAAC", "ACGTTGCAAC"}},
+		{Plan7UniLocal, "GGACGTTGCAACGGGGACGTTGCAACGG",
+			[]string{"ACGTTGCAAC"}},
+		{Plan7Glocal, "GGACGTTGCAACGGGGACGTTGCAACGG",
+			[]string{"ACGTTGCAAC", "ACGTTGCAAC"}},
+		{Plan7UniGlocal, "GGGGACGTTGCAACGGGG", []string{"ACGTTGCAAC"}},
+		{Plan7Local, "GGGGACGTTAGCAACGGGG", []string{"ACGTTaGCAAC"}},
+
+		{Plan7Local, "CCCCTTGCACCCC", []string{"---TTGCA--"}},
+	}
+	for _, test := range tests {
+		p := NewPlan7Profile(hmm, test.mode)
+		got := domainA2M(p, test.seq)
+		if strings.Join(got, " ") != strings.Join(test.domains, " ") {
+			t.Fatalf("Expected domains %v for '%s' (mode %d) but got %v.",
+				test.domains, test.seq, test.mode, got)
+		}
+	}
+
+	// Only glocal mode must visit every node to align a fragment.
+	fragment := NewSequenceString("fragment", "CCCCTTGCACCCC")
+	for _, mode := range []Plan7Mode{Plan7Local, Plan7Glocal} {
+		_, path := NewPlan7Profile(hmm, mode).Viterbi(fragment)
+		domain := path.Domains()[0]
+		first, last := domain[0], domain[len(domain)-1]
+		visitsAll := first.Node == 1 && last.Node == len(hmm.Nodes)-1
+		if visitsAll != (mode == Plan7Glocal) {
+			t.Fatalf("Expected the domain %v (mode %d) to visit every node "+
+				"only in glocal mode.", domain, mode)
+		}
+	}
+
+	// A sequence with a domain should score better than zero, while a
+	// sequence without one shouldn't.
+	local := NewPlan7Profile(hmm, Plan7Local)
+	withDomain := NewSequenceString("with", "GGGGGGACGTTGCAACGGGGGG")
+	without := NewSequenceString("without", "GGGGGGGGGGGGGGGGGGGGGG")
+	if score := local.Forward(withDomain); score >= 0 {
+		t.Fatalf("Expected a negative score for '%s' but got %s.",
+			withDomain.Bytes(), score)
+	}
+	if score := local.Forward(without); score <= 0 {
+		t.Fatalf("Expected a positive score for '%s' but got %s.",
+			without.Bytes(), score)
+	}
+}
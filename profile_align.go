@@ -0,0 +1,318 @@
+package seq
+
+import (
+	"fmt"
+	"math"
+)
+
+// ProfileAlignConfig describes an alignment of a sequence to a profile, which
+// scores each residue with the log-odds score of the profile column it is
+// aligned to. This is how a query is scored against a position-specific
+// scoring matrix (PSSM) in PSI-BLAST.
+//
+// Scores are in the same units as the profile: nats when the profile was
+// built with FrequencyProfile.Profile. Note that a Profile stores negative
+// log-odds scores (see Prob), so the score of a residue in a column is the
+// negation of EProbs.Lookup. A minimal probability can never be aligned.
+type ProfileAlignConfig struct {
+	// The costs of opening and extending a gap, with the same convention as
+	// AlignConfig: a gap of length k has a penalty of GapOpen + k*GapExtend.
+	// These are used when ColumnGapOpen and ColumnGapExtend are nil.
+	GapOpen, GapExtend float64
+
+	// Position-specific gap costs, which must have one entry for every
+	// column of the profile when they are not nil. Deleting a run of columns
+	// costs the opening cost of its first column plus the extension cost of
+	// each column. Inserting residues after a column uses the gap costs of
+	// that column. (Insertions before the first column use the gap costs of
+	// the first column.)
+	ColumnGapOpen, ColumnGapExtend []float64
+
+	Mode AlignMode
+
+	// Terminal gaps that are free, in addition to the ones implied by the
+	// mode. The profile is A and the sequence is B. This is ignored for
+	// local alignment.
+	FreeEnds EndGaps
+}
+
+// NewProfileAlignConfig returns a configuration for local alignment with gap
+// costs in nats that are equivalent to the usual BLOSUM62 gap costs of 11/1
+// (since BLOSUM62 is in half-bit units).
+func NewProfileAlignConfig() ProfileAlignConfig {
+	halfBit := math.Ln2 / 2
+	return ProfileAlignConfig{
+		GapOpen:   11 * halfBit,
+		GapExtend: 1 * halfBit,
+		Mode:      AlignLocal,
+	}
+}
+
+// ProfileAlignment is the alignment of a sequence to a profile, or of two
+// profiles.
+type ProfileAlignment struct {
+	// The alignment of the profile (A) and the sequence or other profile
+	// (B). Since a profile has no residues, each column of a profile is
+	// represented by its consensus residue: the residue with the highest
+	// score. Alignment.Score is Score rounded to the nearest integer.
+	Alignment Alignment
+
+	// The exact score of the alignment.
+	Score float64
+
+	// Columns has one entry for every column of the profile A, which is the
+	// index of the residue (or column) of B aligned to it, or -1 if the
+	// column is aligned to a gap or not covered by the alignment.
+	Columns []int
+}
+
+// gapCosts returns the costs of opening and extending a gap at profile
+// column k. The opening cost includes the cost of its first extension.
+func (c ProfileAlignConfig) gapCosts(k int) (float64, float64) {
+	if c.ColumnGapOpen == nil {
+		return c.GapOpen + c.GapExtend, c.GapExtend
+	}
+	return c.ColumnGapOpen[k] + c.ColumnGapExtend[k], c.ColumnGapExtend[k]
+}
+
+// Align computes the optimal alignment of the sequence to the profile
+// according to the configuration.
+//
+// Residues that aren't in the alphabet of the profile are scored as 'X'. If
+// the alphabet has no 'X', then Align panics with a message describing the
+// offending residue. Align also panics if position-specific gap costs are
+// given for the wrong number of columns.
+func (c ProfileAlignConfig) Align(prof *Profile, s Sequence) ProfileAlignment {
+	M := prof.Len()
+	if c.ColumnGapOpen != nil || c.ColumnGapExtend != nil {
+		if len(c.ColumnGapOpen) != M || len(c.ColumnGapExtend) != M {
+			panic(fmt.Sprintf("Profile has length %d but there are %d gap "+
+				"open costs and %d gap extension costs.",
+				M, len(c.ColumnGapOpen), len(c.ColumnGapExtend)))
+		}
+	}
+	if M == 0 {
+		// With no columns, insertions use the default gap costs.
+		c.ColumnGapOpen, c.ColumnGapExtend = nil, nil
+	}
+
+	index := prof.Alphabet.StrictIndex()
+	for i, r := range s.Residues {
+		if index.Contains(r) {
+			continue
+		}
+		if !index.Contains('X') {
+			panic(fmt.Sprintf("ProfileAlignConfig.Align: residue '%c' at "+
+				"position %d is not in the profile alphabet '%s' (which has "+
+				"no 'X' to use instead)", rune(r), i+1, prof.Alphabet))
+		}
+		index[r] = index['X']
+	}
+	scores := make([][]float64, M)
+	for k, column := range prof.Emissions {
+		scores[k] = make([]float64, len(prof.Alphabet))
+		for a, r := range prof.Alphabet {
+			if prob := column.Lookup(r); prob.IsMin() {
+				scores[k][a] = math.Inf(-1)
+			} else {
+				scores[k][a] = -float64(prob)
+			}
+		}
+	}
+
+	consensus := make([]Residue, M)
+	for k := range consensus {
+		consensus[k] = profileConsensus(prof, k)
+	}
+	score := func(i, j int) float64 {
+		return scores[i][index[s.Residues[j]]]
+	}
+	return alignFloat(consensus, s.Residues, c.Mode, c.FreeEnds, score,
+		c.gapCosts)
+}
+
+// alignFloat is the analogue of AlignConfig.align for real valued scores.
+// The score of aligning A[i] with B[j] is score(i, j), and gapCosts(i)
+// returns the costs of opening (including the first extension) and
+// extending a gap at A[i]. Insertions before A[0] use the gap costs of A[0].
+//
+// The residues are only used to fill in the Alignment returned, and Columns
+// maps each position in A to the position in B aligned to it.
+func alignFloat(
+	A, B []Residue,
+	mode AlignMode,
+	free EndGaps,
+	score func(i, j int) float64,
+	gapCosts func(i int) (float64, float64),
+) ProfileAlignment {
+	// The matrices are the same as in AlignConfig.align. Since scores aren't
+	// integers, the state each cell came from is stored instead of being
+	// recomputed during the traceback. Begin means that the alignment starts
+	// there.
+	rows, cols := len(A)+1, len(B)+1
+	inf := math.Inf(-1)
+	local := mode == AlignLocal
+	ends := AlignConfig{Mode: mode, FreeEnds: free}.endGaps()
+	m, x, y := make([]float64, rows*cols), make([]float64, rows*cols),
+		make([]float64, rows*cols)
+	fm, fx, fy := make([]HMMState, rows*cols), make([]HMMState, rows*cols),
+		make([]HMMState, rows*cols)
+	best3 := func(fromM, fromX, fromY float64) (float64, HMMState) {
+		switch {
+		case fromM >= fromX && fromM >= fromY:
+			return fromM, Match
+		case fromX >= fromY:
+			return fromX, Deletion
+		}
+		return fromY, Insertion
+	}
+
+	for i := 0; i < rows; i++ {
+		m[i*cols], y[i*cols] = inf, inf
+		fx[i*cols] = Deletion
+		switch {
+		case i == 0:
+			m[0], x[0], fm[0] = 0, inf, Begin
+		case local:
+			x[i*cols] = inf
+		case ends.StartA:
+			x[i*cols], fx[i*cols] = 0, Begin
+		default:
+			open, ext := gapCosts(i - 1)
+			x[i*cols] = x[(i-1)*cols] - ext
+			if i == 1 {
+				x[i*cols], fx[i*cols] = -open, Match
+			}
+		}
+	}
+	for j := 1; j < cols; j++ {
+		m[j], x[j] = inf, inf
+		fy[j] = Insertion
+		switch {
+		case local:
+			y[j] = inf
+		case ends.StartB:
+			y[j], fy[j] = 0, Begin
+		default:
+			open, ext := gapCosts(0)
+			y[j] = y[j-1] - ext
+			if j == 1 {
+				y[j], fy[j] = -open, Match
+			}
+		}
+	}
+
+	best, besti, bestj := inf, 0, 0
+	for i := 1; i < rows; i++ {
+		open, ext := gapCosts(i - 1)
+		for j := 1; j < cols; j++ {
+			p, d, u, l := i*cols+j, (i-1)*cols+j-1, (i-1)*cols+j, i*cols+j-1
+
+			prev, from := best3(m[d], x[d], y[d])
+			if local && prev < 0 {
+				prev, from = 0, Begin
+			}
+			m[p], fm[p] = prev+score(i-1, j-1), from
+			x[p], fx[p] = best3(m[u]-open, x[u]-ext, y[u]-open)
+			y[p], fy[p] = best3(m[l]-open, x[l]-open, y[l]-ext)
+			if local && m[p] > best {
+				best, besti, bestj = m[p], i, j
+			}
+		}
+	}
+
+	// Find the cell and state to start the traceback from.
+	var state HMMState
+	i, j := rows-1, cols-1
+	if local {
+		if best <= 0 {
+			return ProfileAlignment{
+				Alignment: newAlignment(0),
+				Columns:   profileColumns(len(A)),
+			}
+		}
+		i, j, state = besti, bestj, Match
+	} else {
+		best = inf
+		consider := func(ci, cj int) {
+			p := ci*cols + cj
+			for _, cand := range []struct {
+				score float64
+				state HMMState
+			}{{m[p], Match}, {x[p], Deletion}, {y[p], Insertion}} {
+				if cand.score > best {
+					best, i, j, state = cand.score, ci, cj, cand.state
+				}
+			}
+		}
+		consider(rows-1, cols-1)
+		if ends.EndA {
+			for ci := 0; ci < rows; ci++ {
+				consider(ci, cols-1)
+			}
+		}
+		if ends.EndB {
+			for cj := 0; cj < cols; cj++ {
+				consider(rows-1, cj)
+			}
+		}
+	}
+
+	aligned := ProfileAlignment{
+		Alignment: newAlignment(max(i, j)),
+		Score:     best,
+		Columns:   profileColumns(len(A)),
+	}
+	aend, bend := i, j
+	for (i > 0 || j > 0) && state != Begin {
+		if (j == 0 && ends.StartA) || (i == 0 && ends.StartB) {
+			break
+		}
+		p := i*cols + j
+		switch state {
+		case Match:
+			aligned.Alignment.A = append(aligned.Alignment.A, A[i-1])
+			aligned.Alignment.B = append(aligned.Alignment.B, B[j-1])
+			aligned.Columns[i-1] = j - 1
+			state = fm[p]
+			i--
+			j--
+		case Deletion:
+			aligned.Alignment.A = append(aligned.Alignment.A, A[i-1])
+			aligned.Alignment.B = append(aligned.Alignment.B, '-')
+			state = fx[p]
+			i--
+		case Insertion:
+			aligned.Alignment.A = append(aligned.Alignment.A, '-')
+			aligned.Alignment.B = append(aligned.Alignment.B, B[j-1])
+			state = fy[p]
+			j--
+		}
+	}
+	reverseAlignment(aligned.Alignment)
+	aligned.Alignment.Score = int(math.Round(best))
+	aligned.Alignment.AStart, aligned.Alignment.AEnd = i, aend
+	aligned.Alignment.BStart, aligned.Alignment.BEnd = j, bend
+	return aligned
+}
+
+// profileColumns returns a column mapping with every column unaligned.
+func profileColumns(n int) []int {
+	columns := make([]int, n)
+	for k := range columns {
+		columns[k] = -1
+	}
+	return columns
+}
+
+// profileConsensus returns the residue with the highest score in column k of
+// the profile, preferring residues that come first in the alphabet.
+func profileConsensus(p *Profile, k int) Residue {
+	best := p.Alphabet[0]
+	for _, r := range p.Alphabet[1:] {
+		if p.Emissions[k].Lookup(r) < p.Emissions[k].Lookup(best) {
+			best = r
+		}
+	}
+	return best
+}
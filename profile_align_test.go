@@ -0,0 +1,105 @@
+package seq
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// substProfile returns a profile whose columns score residues like the rows
+// of the substitution matrix for each residue of the sequence given.
+func substProfile(subst SubstMatrix, rs []Residue) *Profile {
+	index := subst.Alphabet.Index()
+	prof := NewProfileAlphabet(len(rs), subst.Alphabet)
+	for k, r := range rs {
+		for b, r2 := range subst.Alphabet {
+			prof.Emissions[k].Set(r2, -Prob(subst.Scores[index[r]][b]))
+		}
+	}
+	return prof
+}
+
+func TestProfileAlignSubst(t *testing.T) {
+	// Aligning to a profile built from a substitution matrix should give the
+	// same scores as aligning to the sequence with the matrix.
+	const alphabet = "ARNDCQEGHILKMFPSTWYV"
+	rng := rand.New(rand.NewSource(1))
+	modes := []AlignMode{AlignGlobal, AlignLocal, AlignSemiGlobal, AlignOverlap}
+	for trial := 0; trial < 40; trial++ {
+		A := randomResidues(rng, 10+rng.Intn(40), alphabet)
+		B := mutate(rng, A[rng.Intn(5):], rng.Intn(15), alphabet)
+		if rng.Intn(4) == 0 {
+			B = randomResidues(rng, 10+rng.Intn(40), alphabet)
+		}
+		conf := NewAlignConfig(SubstBlosum62)
+		conf.Mode = modes[trial%len(modes)]
+		pconf := ProfileAlignConfig{GapOpen: 11, GapExtend: 1, Mode: conf.Mode}
+
+		expected := conf.Align(A, B)
+		prof := substProfile(SubstBlosum62, A)
+		got := pconf.Align(prof, Sequence{Residues: B})
+		if got.Score != float64(expected.Score) {
+			t.Fatalf("Expected score %d but got %f for\n%s\n%s\n(mode %d)",
+				expected.Score, got.Score, resString(A), resString(B),
+				conf.Mode)
+		}
+		if got.Alignment.Score != expected.Score {
+			t.Fatalf("Expected alignment score %d but got %d.",
+				expected.Score, got.Alignment.Score)
+		}
+		covered := resString(B[got.Alignment.BStart:got.Alignment.BEnd])
+		if ungap(got.Alignment.B) != covered {
+			t.Fatalf("Alignment %s does not cover %s.",
+				resString(got.Alignment.B), covered)
+		}
+
+		// The column mapping must agree with the alignment.
+		k, j := got.Alignment.AStart, got.Alignment.BStart
+		for c := range got.Alignment.A {
+			a, b := got.Alignment.A[c], got.Alignment.B[c]
+			switch {
+			case a == '-':
+				j++
+			case b == '-':
+				if got.Columns[k] != -1 {
+					t.Fatalf("Expected column %d to be deleted but it is "+
+						"aligned to %d.", k, got.Columns[k])
+				}
+				k++
+			default:
+				if got.Columns[k] != j {
+					t.Fatalf("Expected column %d to be aligned to %d but "+
+						"it is aligned to %d.", k, j, got.Columns[k])
+				}
+				k++
+				j++
+			}
+		}
+	}
+}
+
+func TestProfileAlignColumnGaps(t *testing.T) {
+	// The sequence is missing one of the two W columns. Gaps are cheap in
+	// the second W column only, so it must be the one that is deleted.
+	prof := substProfile(SubstBlosum62, stringToSeq("HIKWWLMN"))
+	conf := ProfileAlignConfig{Mode: AlignGlobal}
+	for k := 0; k < prof.Len(); k++ {
+		conf.ColumnGapOpen = append(conf.ColumnGapOpen, 11)
+		conf.ColumnGapExtend = append(conf.ColumnGapExtend, 1)
+	}
+	conf.ColumnGapOpen[4] = 0
+
+	got := conf.Align(prof, Sequence{Residues: stringToSeq("HIKWLMN")})
+	expected := []int{0, 1, 2, 3, -1, 4, 5, 6}
+	for k := range expected {
+		if got.Columns[k] != expected[k] {
+			t.Fatalf("Expected columns %v but got %v.", expected, got.Columns)
+		}
+	}
+	if s := resString(got.Alignment.A); s != "HIKWWLMN" {
+		t.Fatalf("Expected consensus 'HIKWWLMN' but got '%s'.", s)
+	}
+	// The matches score 8+4+5+11+4+5+6 and the gap costs 0+1.
+	if got.Score != 42 {
+		t.Fatalf("Expected score 42 but got %f.", got.Score)
+	}
+}
@@ -0,0 +1,195 @@
+package seq
+
+import (
+	"fmt"
+	"math"
+)
+
+// ColumnScorer scores the similarity of two profile columns, where higher
+// scores mean the columns are more similar. Each column is given as a
+// probability distribution over the alphabet of the profiles, and null is
+// the background distribution.
+type ColumnScorer func(p1, p2, null []float64) float64
+
+// ColumnDotProduct is the logarithm (in nats) of the dot product of the
+// probabilities of one column with the odds of the other:
+//
+//	ln(sum_a p1(a) * p2(a) / null(a))
+//
+// This is the log-odds score used by HHsearch. It is zero when either
+// column is the background distribution. Residues with a background
+// probability of zero are ignored.
+func ColumnDotProduct(p1, p2, null []float64) float64 {
+	dot := 0.0
+	for a := range p1 {
+		if null[a] > 0 {
+			dot += p1[a] * p2[a] / null[a]
+		}
+	}
+	return math.Log(dot)
+}
+
+// ColumnPearson is the Pearson correlation coefficient of the probabilities
+// of the two columns, which is in the range [-1, 1]. If either column is
+// uniform, then the score is 0.
+func ColumnPearson(p1, p2, null []float64) float64 {
+	n := float64(len(p1))
+	mean1, mean2 := 0.0, 0.0
+	for a := range p1 {
+		mean1 += p1[a]
+		mean2 += p2[a]
+	}
+	mean1, mean2 = mean1/n, mean2/n
+	cov, var1, var2 := 0.0, 0.0, 0.0
+	for a := range p1 {
+		d1, d2 := p1[a]-mean1, p2[a]-mean2
+		cov += d1 * d2
+		var1 += d1 * d1
+		var2 += d2 * d2
+	}
+	if var1 == 0 || var2 == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(var1*var2)
+}
+
+// ColumnJensenShannon is one minus the Jensen-Shannon divergence (in bits)
+// of the two columns, which is in the range [0, 1]. Identical columns score
+// 1, and columns with no residues in common score 0.
+func ColumnJensenShannon(p1, p2, null []float64) float64 {
+	div := 0.0
+	for a := range p1 {
+		mid := (p1[a] + p2[a]) / 2
+		if p1[a] > 0 {
+			div += p1[a] * math.Log2(p1[a]/mid)
+		}
+		if p2[a] > 0 {
+			div += p2[a] * math.Log2(p2[a]/mid)
+		}
+	}
+	return 1 - div/2
+}
+
+// ProfileProfileConfig describes an alignment of two profiles, which scores
+// each pair of aligned columns with a ColumnScorer.
+type ProfileProfileConfig struct {
+	Scorer ColumnScorer
+
+	// The background frequencies used to convert the log-odds scores of the
+	// profiles to probabilities. This is a frequency profile with a single
+	// column, like the null model given to FrequencyProfile.Profile.
+	Null *FrequencyProfile
+
+	// Shift is added to the score of every pair of columns. For local
+	// alignment, the expected score of a pair of unrelated columns must be
+	// negative, which requires a negative shift for scorers like
+	// ColumnJensenShannon that are never negative.
+	Shift float64
+
+	// The costs of opening and extending a gap, in the units of the scorer,
+	// with the same convention as AlignConfig.
+	GapOpen, GapExtend float64
+
+	Mode AlignMode
+
+	// Terminal gaps that are free, in addition to the ones implied by the
+	// mode. This is ignored for local alignment.
+	FreeEnds EndGaps
+}
+
+// NewProfileProfileConfig returns a configuration for local alignment with
+// ColumnDotProduct and the background frequencies given. The gap costs are
+// the same as the ones used by NewProfileAlignConfig.
+func NewProfileProfileConfig(null *FrequencyProfile) ProfileProfileConfig {
+	pconf := NewProfileAlignConfig()
+	return ProfileProfileConfig{
+		Scorer:    ColumnDotProduct,
+		Null:      null,
+		GapOpen:   pconf.GapOpen,
+		GapExtend: pconf.GapExtend,
+		Mode:      AlignLocal,
+	}
+}
+
+// Align computes the optimal alignment of the two profiles according to the
+// configuration. In the ProfileAlignment returned, each profile is
+// represented by its consensus residues, and Columns maps each column of A
+// to the column of B aligned to it.
+//
+// Both profiles must have the same alphabet, and the null model must have
+// exactly one column, otherwise Align panics.
+func (c ProfileProfileConfig) Align(A, B *Profile) ProfileAlignment {
+	if !A.Alphabet.Equals(B.Alphabet) {
+		panic(fmt.Sprintf("Profile alphabet '%s' is not equal to other "+
+			"profile alphabet '%s'.", A.Alphabet, B.Alphabet))
+	}
+	if c.Null.Len() != 1 {
+		panic(fmt.Sprintf("null model has %d columns; should have 1",
+			c.Null.Len()))
+	}
+
+	null := make([]float64, len(A.Alphabet))
+	tot := 0.0
+	for a, r := range A.Alphabet {
+		null[a] = float64(c.Null.Freqs[0][r])
+		tot += null[a]
+	}
+	if tot == 0 {
+		panic(fmt.Sprintf("null model has no residues in the alphabet '%s'",
+			A.Alphabet))
+	}
+	for a := range null {
+		null[a] /= tot
+	}
+
+	probsA, probsB := profileProbs(A, null), profileProbs(B, null)
+	scores := make([][]float64, len(probsA))
+	for i := range scores {
+		scores[i] = make([]float64, len(probsB))
+		for j := range scores[i] {
+			scores[i][j] = c.Scorer(probsA[i], probsB[j], null) + c.Shift
+		}
+	}
+
+	consensusA := make([]Residue, A.Len())
+	for k := range consensusA {
+		consensusA[k] = profileConsensus(A, k)
+	}
+	consensusB := make([]Residue, B.Len())
+	for k := range consensusB {
+		consensusB[k] = profileConsensus(B, k)
+	}
+	score := func(i, j int) float64 {
+		return scores[i][j]
+	}
+	gapCosts := func(i int) (float64, float64) {
+		return c.GapOpen + c.GapExtend, c.GapExtend
+	}
+	return alignFloat(consensusA, consensusB, c.Mode, c.FreeEnds, score,
+		gapCosts)
+}
+
+// profileProbs converts the log-odds scores of each column of the profile to
+// probabilities with the background distribution given. The probabilities
+// are normalized, since a profile's scores need not correspond to a proper
+// distribution. A column with no probability at all is given the
+// background distribution.
+func profileProbs(p *Profile, null []float64) [][]float64 {
+	probs := make([][]float64, p.Len())
+	for k, column := range p.Emissions {
+		probs[k] = make([]float64, len(p.Alphabet))
+		tot := 0.0
+		for a, r := range p.Alphabet {
+			probs[k][a] = null[a] * column.Lookup(r).Ratio()
+			tot += probs[k][a]
+		}
+		if tot == 0 {
+			copy(probs[k], null)
+			continue
+		}
+		for a := range probs[k] {
+			probs[k][a] /= tot
+		}
+	}
+	return probs
+}
@@ -0,0 +1,104 @@
+package seq
+
+import (
+	"math"
+	"testing"
+)
+
+// mixProfile returns a profile where each column is an even mixture of the
+// background and the corresponding residue of the sequence.
+func mixProfile(null *FrequencyProfile, rs []Residue) *Profile {
+	tot := float64(freqTotal(null.Freqs[0]))
+	prof := NewProfileAlphabet(len(rs), null.Alphabet)
+	for k, r := range rs {
+		for _, r2 := range null.Alphabet {
+			q := float64(null.Freqs[0][r2]) / tot
+			if q == 0 {
+				continue
+			}
+			p := q / 2
+			if r2 == r {
+				p += 0.5
+			}
+			prof.Emissions[k].Set(r2, -Prob(math.Log(p/q)))
+		}
+	}
+	return prof
+}
+
+func TestColumnScorers(t *testing.T) {
+	null := []float64{0.5, 0.25, 0.25}
+	col := []float64{0.1, 0.2, 0.7}
+	disjoint := []float64{1, 0, 0}
+	tests := []struct {
+		name     string
+		score    float64
+		expected float64
+	}{
+		{"dot product with background", ColumnDotProduct(col, null, null), 0},
+		{"dot product", ColumnDotProduct(col, col, null),
+			math.Log(0.01/0.5 + 0.04/0.25 + 0.49/0.25)},
+		{"pearson with itself", ColumnPearson(col, col, null), 1},
+		{"jensen-shannon with itself", ColumnJensenShannon(col, col, null), 1},
+		{"jensen-shannon disjoint",
+			ColumnJensenShannon(disjoint, []float64{0, 0.5, 0.5}, null), 0},
+	}
+	for _, test := range tests {
+		if math.Abs(test.score-test.expected) > 1e-12 {
+			t.Fatalf("Expected %s score %f but got %f.",
+				test.name, test.expected, test.score)
+		}
+	}
+}
+
+func TestProfileProfileAlign(t *testing.T) {
+	null := robinsonNull()
+	A := mixProfile(null, stringToSeq("MKVLAGHEWTRNDFCY"))
+	B := mixProfile(null, stringToSeq("MKVLAGTRNDFCY"))
+	scorers := []struct {
+		name   string
+		scorer ColumnScorer
+		shift  float64
+	}{
+		{"dot product", ColumnDotProduct, 0},
+		{"pearson", ColumnPearson, -0.5},
+		{"jensen-shannon", ColumnJensenShannon, -0.5},
+	}
+	for _, s := range scorers {
+		conf := NewProfileProfileConfig(null)
+		conf.Scorer, conf.Shift = s.scorer, s.shift
+		conf.GapOpen, conf.GapExtend = 1, 0.1
+
+		// Aligning a profile to itself matches every column.
+		self := conf.Align(A, A)
+		for k, c := range self.Columns {
+			if c != k {
+				t.Fatalf("Expected column %d to be aligned to itself with %s "+
+					"but got %v.", k, s.name, self.Columns)
+			}
+		}
+		if got := resString(self.Alignment.A); got != "MKVLAGHEWTRNDFCY" {
+			t.Fatalf("Expected consensus 'MKVLAGHEWTRNDFCY' but got '%s'.",
+				got)
+		}
+
+		// The columns H, E and W of A are missing from B.
+		for _, mode := range []AlignMode{AlignGlobal, AlignLocal} {
+			conf.Mode = mode
+			aligned := conf.Align(A, B)
+			expected := []int{0, 1, 2, 3, 4, 5, -1, -1, -1,
+				6, 7, 8, 9, 10, 11, 12}
+			for k := range expected {
+				if aligned.Columns[k] != expected[k] {
+					t.Fatalf("Expected columns %v with %s (mode %d) but "+
+						"got %v.", expected, s.name, mode, aligned.Columns)
+				}
+			}
+			got := resString(aligned.Alignment.B)
+			if got != "MKVLAG---TRNDFCY" {
+				t.Fatalf("Expected 'MKVLAG---TRNDFCY' with %s (mode %d) "+
+					"but got '%s'.", s.name, mode, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package seq
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SAMUnmapped is the SAM flag for an unmapped query.
+const SAMUnmapped = 0x4
+
+// SAMRecord corresponds to a single alignment line in a SAM file. Positions
+// are 1-indexed, as in the SAM format.
+type SAMRecord struct {
+	QName string
+	Flag  int
+	RName string
+	Pos   int
+	MapQ  int
+	Cigar Cigar
+	RNext string
+	PNext int
+	TLen  int
+	Seq   []Residue
+	Qual  string
+
+	// Optional fields, e.g., "AS:i:42".
+	Tags []string
+}
+
+// NewSAMRecord creates a SAM record from a pairwise alignment of a query
+// sequence to a reference sequence. The alignment must have been computed
+// with the reference as A and the query as B.
+//
+// The mapping quality is set to 255 (unavailable), and the score and edit
+// distance of the alignment are included as the AS and NM tags. (Residues
+// that differ only in case are not counted as edits.) If the alignment is
+// empty, the query is reported as unmapped.
+//
+// Only the first word of each sequence name is used, since SAM names may not
+// contain white space.
+func NewSAMRecord(ref, query Sequence, a Alignment, extended bool) SAMRecord {
+	rec := SAMRecord{
+		QName: samName(query.Name),
+		RName: "*",
+		MapQ:  255,
+		RNext: "*",
+		Seq:   query.Residues,
+		Qual:  "*",
+	}
+	if a.Len() == 0 {
+		rec.Flag = SAMUnmapped
+		rec.MapQ = 0
+		return rec
+	}
+
+	edits := 0
+	for i := range a.A {
+		if !sameResidue(a.A[i], a.B[i]) {
+			edits++
+		}
+	}
+	rec.RName = samName(ref.Name)
+	rec.Pos = a.AStart + 1
+	rec.Cigar = a.Cigar(query.Len(), extended)
+	rec.Tags = []string{
+		fmt.Sprintf("AS:i:%d", a.Score),
+		fmt.Sprintf("NM:i:%d", edits),
+	}
+	return rec
+}
+
+// String returns the record as a single tab-delimited SAM line (without a
+// trailing new line).
+func (r SAMRecord) String() string {
+	seq := "*"
+	if len(r.Seq) > 0 {
+		seq = fmt.Sprintf("%s", r.Seq)
+	}
+	fields := []string{
+		r.QName,
+		fmt.Sprintf("%d", r.Flag),
+		r.RName,
+		fmt.Sprintf("%d", r.Pos),
+		fmt.Sprintf("%d", r.MapQ),
+		r.Cigar.String(),
+		r.RNext,
+		fmt.Sprintf("%d", r.PNext),
+		fmt.Sprintf("%d", r.TLen),
+		seq,
+		r.Qual,
+	}
+	return strings.Join(append(fields, r.Tags...), "\t")
+}
+
+// SAMWriter writes SAM formatted alignments.
+type SAMWriter struct {
+	w *bufio.Writer
+}
+
+// NewSAMWriter creates a new SAM writer that writes to w.
+func NewSAMWriter(w io.Writer) *SAMWriter {
+	return &SAMWriter{bufio.NewWriter(w)}
+}
+
+// WriteHeader writes a SAM header with a line for each reference sequence.
+// It should be called before any records are written.
+func (w *SAMWriter) WriteHeader(refs []Sequence) error {
+	if _, err := fmt.Fprintf(w.w, "@HD\tVN:1.6\tSO:unsorted\n"); err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		_, err := fmt.Fprintf(w.w, "@SQ\tSN:%s\tLN:%d\n",
+			samName(ref.Name), ref.Len())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write writes a single record. Output is buffered, so Flush must be called
+// when writing is finished.
+func (w *SAMWriter) Write(r SAMRecord) error {
+	_, err := fmt.Fprintf(w.w, "%s\n", r)
+	return err
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *SAMWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// samName returns the first word of name, or "*" if name is empty.
+func samName(name string) string {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return "*"
+	}
+	return fields[0]
+}
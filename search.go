@@ -0,0 +1,478 @@
+package seq
+
+import (
+	"sort"
+)
+
+// SearchDB is a database of sequences indexed by word (k-mer), for use with
+// SearchConfig.Search.
+type SearchDB struct {
+	// The sequences in the database. This should not be modified once the
+	// database has been created.
+	Seqs []Sequence
+
+	// The length of the words in the index.
+	WordSize int
+
+	// The residues that may appear in words, and a map from each residue to
+	// its position in letters (or -1 if it isn't one).
+	letters   []Residue
+	letterIdx AlphabetIndex
+
+	// Map from the code of a word (see wordCode) to every occurrence of the
+	// word in the database.
+	index map[int][]searchWord
+
+	// The total number of residues in the database.
+	residues int
+}
+
+// searchWord is the location of an occurrence of a word in a SearchDB.
+type searchWord struct {
+	seq, pos int
+}
+
+// NewSearchDB indexes every word of length wordSize in the sequences given.
+// Words are made of residues in the alphabet, excluding the gap character
+// '-' and the stop character '*'. Words that contain any other residue are
+// not indexed. The alphabet should usually be the alphabet of the
+// substitution matrix that will be used to search the database.
+//
+// A word size of 3 is typical for protein sequences.
+func NewSearchDB(seqs []Sequence, alphabet Alphabet, wordSize int) *SearchDB {
+	db := &SearchDB{
+		Seqs:     seqs,
+		WordSize: wordSize,
+		index:    make(map[int][]searchWord),
+	}
+	for i := range db.letterIdx {
+		db.letterIdx[i] = -1
+	}
+	for _, r := range alphabet {
+		if r != '-' && r != '*' && !db.letterIdx.Contains(r) {
+			db.letterIdx[r] = len(db.letters)
+			db.letters = append(db.letters, r)
+		}
+	}
+	for i, s := range seqs {
+		db.residues += s.Len()
+		for pos := 0; pos+wordSize <= s.Len(); pos++ {
+			if code, ok := db.wordCode(s.Residues[pos : pos+wordSize]); ok {
+				db.index[code] = append(db.index[code], searchWord{i, pos})
+			}
+		}
+	}
+	return db
+}
+
+// Len returns the total number of residues in the database.
+func (db *SearchDB) Len() int {
+	return db.residues
+}
+
+// wordCode returns a unique integer for the word, which is the word read as
+// a number in base len(letters). If the word contains a residue that isn't
+// a letter, then false is returned.
+func (db *SearchDB) wordCode(word []Residue) (int, bool) {
+	code := 0
+	for _, r := range word {
+		l := db.letterIdx[r]
+		if l < 0 {
+			return 0, false
+		}
+		code = code*len(db.letters) + l
+	}
+	return code, true
+}
+
+// SearchConfig describes a seed and extend database search, in the style of
+// BLAST. The search proceeds in four steps:
+//
+// 1. Every word in the query is expanded into its neighborhood: all words
+// that score at least Threshold when aligned with it. Occurrences of these
+// words in the database are seeds.
+//
+// 2. Seeds are only extended when there is another non-overlapping seed on
+// the same diagonal within Window residues before it (the "two-hit" method).
+//
+// 3. Extension is first ungapped, in both directions, until the score drops
+// more than XDrop below the best score seen.
+//
+// 4. If the ungapped score is at least GappedTrigger, then a gapped local
+// alignment is computed between the query and the region of the database
+// sequence around the ungapped alignment. The alignment is restricted to a
+// band around the diagonal of the ungapped alignment.
+type SearchConfig struct {
+	// The substitution matrix and gap costs used for gapped alignment. The
+	// mode is ignored, since gapped extension is always a local alignment.
+	Align AlignConfig
+
+	// The statistics used to compute E-values of gapped alignments. They
+	// must match the substitution matrix and gap costs in Align.
+	Stats KarlinAltschul
+
+	// The minimum score of a neighborhood word.
+	Threshold int
+
+	// The maximum distance between two seeds on the same diagonal.
+	Window int
+
+	// The drop in score that terminates an ungapped extension.
+	XDrop int
+
+	// The minimum ungapped score that triggers a gapped extension.
+	GappedTrigger int
+
+	// The maximum net number of gaps in either sequence, relative to the
+	// ungapped alignment, in a gapped extension.
+	Band int
+
+	// Hits with an E-value greater than this are not reported.
+	MaxEValue float64
+}
+
+// NewSearchConfig returns a search configuration with BLAST's defaults for
+// protein searches with BLOSUM62: a gap open cost of 11, a gap extension cost
+// of 1, a neighborhood threshold of 11, a two-hit window of 40, an X-drop of
+// 16 (about 7 bits), a gapped trigger of 41 (about 22 bits) and a maximum
+// E-value of 10. Gapped extensions use a band of 32. The search database
+// should use a word size of 3.
+//
+// When using a different substitution matrix, the statistics (and probably
+// the thresholds) must be changed too.
+func NewSearchConfig() SearchConfig {
+	conf := NewAlignConfig(SubstBlosum62)
+	conf.Mode = AlignLocal
+	stats, _ := GappedKarlinAltschul("BLOSUM62", conf.GapOpen, conf.GapExtend)
+	return SearchConfig{
+		Align:         conf,
+		Stats:         stats,
+		Threshold:     11,
+		Window:        40,
+		XDrop:         16,
+		GappedTrigger: 41,
+		Band:          32,
+		MaxEValue:     10,
+	}
+}
+
+// SearchHit is a database sequence with a significant alignment to a query.
+type SearchHit struct {
+	// The index of the sequence in the database.
+	Index int
+
+	// The local alignment of the database sequence (A) and the query (B).
+	// Positions are relative to the full sequences.
+	Alignment Alignment
+
+	BitScore, EValue float64
+}
+
+// Search finds the database sequences with a significant local alignment to
+// the query, in the style of BLAST. At most one hit (the best alignment) is
+// reported for each database sequence. Hits are sorted by E-value, with ties
+// broken by the order of the sequences in the database.
+//
+// E-values are computed with the length of the query and the total length
+// of the database, without any correction for edge effects.
+//
+// As with AlignConfig.Align, residues that aren't in the alphabet of the
+// substitution matrix are scored as described by SubstMatrix.ScoreIndex, and
+// Search panics if a residue of the query cannot be scored. Residues of the
+// database sequences that cannot be scored are never aligned, in either
+// ungapped or gapped extensions.
+func (c SearchConfig) Search(db *SearchDB, query []Residue) []SearchHit {
+	s := searcher{
+		SearchConfig: c,
+		db:           db,
+		query:        query,
+	}
+	s.index()
+
+	// Find seeds, grouped by database sequence.
+	seeds := make([][]searchSeed, len(db.Seqs))
+	s.neighborhood(func(qpos int, code int) {
+		for _, w := range db.index[code] {
+			seeds[w.seq] = append(seeds[w.seq], searchSeed{qpos, w.pos})
+		}
+	})
+
+	conf := c.Align
+	conf.Mode = AlignLocal
+	conf.Subst.Scores = s.scores
+	var hits []SearchHit
+	for seq, sseeds := range seeds {
+		if len(sseeds) == 0 {
+			continue
+		}
+		best, found := Alignment{}, false
+		for _, hsp := range s.ungapped(seq, sseeds) {
+			if found && hsp.within(best) {
+				continue
+			}
+			aligned := s.gapped(conf, seq, hsp)
+			if !found || aligned.Score > best.Score {
+				best, found = aligned, true
+			}
+		}
+		if !found {
+			continue
+		}
+		evalue := c.Stats.EValue(best.Score, len(query), db.Len())
+		if evalue > c.MaxEValue {
+			continue
+		}
+		hits = append(hits, SearchHit{
+			Index:     seq,
+			Alignment: best,
+			BitScore:  c.Stats.BitScore(best.Score),
+			EValue:    evalue,
+		})
+	}
+	sort.Sort(searchHits(hits))
+	return hits
+}
+
+type searchHits []SearchHit
+
+func (hs searchHits) Len() int      { return len(hs) }
+func (hs searchHits) Swap(i, j int) { hs[i], hs[j] = hs[j], hs[i] }
+func (hs searchHits) Less(i, j int) bool {
+	if hs[i].EValue != hs[j].EValue {
+		return hs[i].EValue < hs[j].EValue
+	}
+	return hs[i].Index < hs[j].Index
+}
+
+// searcher holds the state of a single search.
+type searcher struct {
+	SearchConfig
+	db    *SearchDB
+	query []Residue
+
+	// The scores of the substitution matrix, with an extra row and column
+	// (scored as negInf) for residues that cannot be scored, and a map from
+	// every residue to its row.
+	scores [][]int
+	idx    AlphabetIndex
+}
+
+// index sets the scores and the score index of the search. It panics if a
+// residue of the query cannot be scored.
+func (s *searcher) index() {
+	subst := s.Align.Subst
+	s.idx = subst.mustScoreIndex("SearchConfig.Search", s.query)
+	unknown := len(subst.Alphabet)
+	for r := range s.idx {
+		if s.idx[r] < 0 {
+			if s.idx[r] = subst.fallback(s.idx, Residue(r)); s.idx[r] < 0 {
+				s.idx[r] = unknown
+			}
+		}
+	}
+
+	s.scores = make([][]int, unknown+1)
+	for i := range s.scores {
+		s.scores[i] = make([]int, unknown+1)
+		for j := range s.scores[i] {
+			if i < unknown && j < unknown {
+				s.scores[i][j] = subst.Scores[i][j]
+			} else {
+				s.scores[i][j] = negInf
+			}
+		}
+	}
+}
+
+// searchSeed is a word hit at a position in the query and a position in a
+// database sequence.
+type searchSeed struct {
+	qpos, spos int
+}
+
+func (s searchSeed) diagonal() int {
+	return s.spos - s.qpos
+}
+
+// searchHSP is a high scoring ungapped alignment of the query to a database
+// sequence, covering the positions [qstart, qstart+length) in the query and
+// [sstart, sstart+length) in the database sequence.
+type searchHSP struct {
+	qstart, sstart, length, score int
+}
+
+// within returns true if the HSP lies entirely within the region covered by
+// the alignment, where A is the database sequence and B is the query.
+func (h searchHSP) within(a Alignment) bool {
+	return h.sstart >= a.AStart && h.sstart+h.length <= a.AEnd &&
+		h.qstart >= a.BStart && h.qstart+h.length <= a.BEnd
+}
+
+func (s *searcher) score(a, b Residue) int {
+	return s.scores[s.idx[a]][s.idx[b]]
+}
+
+// neighborhood calls f with every word that scores at least Threshold
+// against the word at each position in the query.
+func (s *searcher) neighborhood(f func(qpos int, code int)) {
+	k, letters := s.db.WordSize, s.db.letters
+	if k <= 0 || len(s.query) < k {
+		return
+	}
+
+	// scores[i][l] is the score of the query residue at i with letter l, and
+	// best[i] is the best score of any letter with the query residue at i.
+	scores := make([][]int, len(s.query))
+	best := make([]int, len(s.query))
+	for i, r := range s.query {
+		scores[i] = make([]int, len(letters))
+		best[i] = negInf
+		for l, letter := range letters {
+			scores[i][l] = s.score(r, letter)
+			best[i] = max(best[i], scores[i][l])
+		}
+	}
+
+	// Enumerate words depth first, pruning a prefix once even the best
+	// possible completion can't reach the threshold.
+	var qpos int
+	var extend func(depth, code, score int)
+	extend = func(depth, code, score int) {
+		if depth == k {
+			if score >= s.Threshold {
+				f(qpos, code)
+			}
+			return
+		}
+		rest := 0
+		for i := qpos + depth + 1; i < qpos+k; i++ {
+			rest += best[i]
+		}
+		for l := range letters {
+			sc := score + scores[qpos+depth][l]
+			if sc+rest >= s.Threshold {
+				extend(depth+1, code*len(letters)+l, sc)
+			}
+		}
+	}
+	for qpos = 0; qpos+k <= len(s.query); qpos++ {
+		extend(0, 0, 0)
+	}
+}
+
+// ungapped applies the two-hit method to the seeds in a single database
+// sequence, and returns the ungapped extensions that reach GappedTrigger in
+// order of decreasing score.
+func (s *searcher) ungapped(seq int, seeds []searchSeed) []searchHSP {
+	subject := s.db.Seqs[seq].Residues
+	k, qlen := s.db.WordSize, len(s.query)
+
+	// Sort the seeds by position in the database sequence with a counting
+	// sort, which is much faster than a comparison sort here.
+	starts := make([]int, len(subject)+1)
+	for _, seed := range seeds {
+		starts[seed.spos+1]++
+	}
+	for i := 1; i < len(starts); i++ {
+		starts[i] += starts[i-1]
+	}
+	sorted := make([]searchSeed, len(seeds))
+	for _, seed := range seeds {
+		sorted[starts[seed.spos]] = seed
+		starts[seed.spos]++
+	}
+
+	// For each diagonal (offset by the length of the query), keep track of
+	// the position of the last seed and the end of the last extension.
+	lastSeed := make([]int, len(subject)+qlen)
+	extendedTo := make([]int, len(subject)+qlen)
+	for d := range lastSeed {
+		lastSeed[d] = -1
+	}
+	var hsps []searchHSP
+	for _, seed := range sorted {
+		d := seed.diagonal() + qlen
+		if seed.spos < extendedTo[d] {
+			continue
+		}
+		prev := lastSeed[d]
+		if prev >= 0 && seed.spos-prev < k {
+			// Overlapping seeds don't count as a second hit.
+			continue
+		}
+		lastSeed[d] = seed.spos
+		if prev < 0 || seed.spos-prev > s.Window {
+			continue
+		}
+		hsp := s.extend(subject, seed)
+		extendedTo[d] = hsp.sstart + hsp.length
+		if hsp.score >= s.GappedTrigger {
+			hsps = append(hsps, hsp)
+		}
+	}
+	sort.Sort(searchHSPs(hsps))
+	return hsps
+}
+
+type searchHSPs []searchHSP
+
+func (hs searchHSPs) Len() int           { return len(hs) }
+func (hs searchHSPs) Swap(i, j int)      { hs[i], hs[j] = hs[j], hs[i] }
+func (hs searchHSPs) Less(i, j int) bool { return hs[i].score > hs[j].score }
+
+// extend performs an ungapped X-drop extension of a seed in both directions.
+func (s *searcher) extend(subject []Residue, seed searchSeed) searchHSP {
+	k := s.db.WordSize
+	score := 0
+	for i := 0; i < k; i++ {
+		score += s.score(s.query[seed.qpos+i], subject[seed.spos+i])
+	}
+
+	// Extend to the right.
+	best, right := score, 0
+	for i, sc := 0, score; seed.qpos+k+i < len(s.query) &&
+		seed.spos+k+i < len(subject); i++ {
+		sc += s.score(s.query[seed.qpos+k+i], subject[seed.spos+k+i])
+		if sc > best {
+			best, right = sc, i+1
+		} else if sc < best-s.XDrop {
+			break
+		}
+	}
+
+	// Extend to the left.
+	left := 0
+	for i, sc := 1, best; seed.qpos-i >= 0 && seed.spos-i >= 0; i++ {
+		sc += s.score(s.query[seed.qpos-i], subject[seed.spos-i])
+		if sc > best {
+			best, left = sc, i
+		} else if sc < best-s.XDrop {
+			break
+		}
+	}
+	return searchHSP{
+		qstart: seed.qpos - left,
+		sstart: seed.spos - left,
+		length: left + k + right,
+		score:  best,
+	}
+}
+
+// gapped computes a banded local alignment of the query with the region of
+// the database sequence around an HSP. The band is centered on the diagonal
+// of the HSP and has a radius of Band.
+func (s *searcher) gapped(conf AlignConfig, seq int, hsp searchHSP) Alignment {
+	subject := s.db.Seqs[seq].Residues
+	diag := hsp.sstart - hsp.qstart
+	start := max(0, diag-s.Band)
+	end := min(len(subject), diag+len(s.query)+s.Band)
+
+	// In the region, the HSP lies on the diagonal j - i = start - diag.
+	A, B := subject[start:end], s.query
+	lo, hi := start-diag-s.Band, start-diag+s.Band
+	t := newAffineTableBand(len(A)+1, len(B)+1, hi-lo+1, 1, lo)
+	aligned := conf.alignIndex(A, B, t, s.idx)
+	aligned.AStart += start
+	aligned.AEnd += start
+	return aligned
+}
@@ -0,0 +1,145 @@
+package seq
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	const aminos = "ACDEFGHIKLMNPQRSTVWY"
+	rng := rand.New(rand.NewSource(1))
+	query := randomResidues(rng, 120, aminos)
+
+	// A database of random sequences, with two homologs of the query
+	// embedded in longer sequences.
+	var seqs []Sequence
+	for i := 0; i < 200; i++ {
+		seqs = append(seqs, Sequence{
+			Name:     fmt.Sprintf("random%d", i),
+			Residues: randomResidues(rng, 100+rng.Intn(300), aminos),
+		})
+	}
+	embed := func(i int, n int) {
+		homolog := mutate(rng, query, n, aminos)
+		rs := randomResidues(rng, 50, aminos)
+		rs = append(rs, homolog...)
+		rs = append(rs, randomResidues(rng, 70, aminos)...)
+		seqs[i].Residues = rs
+	}
+	embed(42, 20)
+	embed(7, 45)
+
+	db := NewSearchDB(seqs, SubstBlosum62.Alphabet, 3)
+	conf := NewSearchConfig()
+	conf.MaxEValue = 1e-3
+	hits := conf.Search(db, query)
+	if len(hits) != 2 {
+		t.Fatalf("Expected 2 hits but got %d: %+v", len(hits), hits)
+	}
+	if hits[0].Index != 42 || hits[1].Index != 7 {
+		t.Fatalf("Expected hits to sequences 42 and 7, but got %d and %d.",
+			hits[0].Index, hits[1].Index)
+	}
+	for _, hit := range hits {
+		full := conf.Align.Align(seqs[hit.Index].Residues, query)
+		if hit.Alignment.Score != full.Score {
+			t.Fatalf("Expected the score of the full local alignment, %d, "+
+				"but got %d.", full.Score, hit.Alignment.Score)
+		}
+		testAffineConsistent(t, conf.Align, seqs[hit.Index].Residues, query,
+			hit.Alignment)
+		if hit.EValue > conf.MaxEValue {
+			t.Fatalf("E-value %g exceeds the maximum.", hit.EValue)
+		}
+	}
+}
+
+func TestSearchDiagonals(t *testing.T) {
+	const aminos = "ACDEFGHIKLMNPQRSTVWY"
+	rng := rand.New(rand.NewSource(2))
+	domain := randomResidues(rng, 120, aminos)
+
+	// The domain starts later in the query than in the database sequence,
+	// and vice versa.
+	query := randomResidues(rng, 80, aminos)
+	query = append(query, domain...)
+	query = append(query, randomResidues(rng, 40, aminos)...)
+	seqs := []Sequence{
+		{Name: "start", Residues: append(append([]Residue{}, domain...),
+			randomResidues(rng, 100, aminos)...)},
+		{Name: "end", Residues: append(randomResidues(rng, 200, aminos),
+			domain...)},
+	}
+	db := NewSearchDB(seqs, SubstBlosum62.Alphabet, 3)
+	conf := NewSearchConfig()
+	conf.MaxEValue = 1e-3
+	hits := conf.Search(db, query)
+	if len(hits) != 2 {
+		t.Fatalf("Expected 2 hits but got %d: %+v", len(hits), hits)
+	}
+	for _, hit := range hits {
+		full := conf.Align.Align(seqs[hit.Index].Residues, query)
+		if hit.Alignment.Score != full.Score {
+			t.Fatalf("%s: expected the score of the full local alignment, "+
+				"%d, but got %d.", seqs[hit.Index].Name, full.Score,
+				hit.Alignment.Score)
+		}
+		testAffineConsistent(t, conf.Align, seqs[hit.Index].Residues, query,
+			hit.Alignment)
+	}
+}
+
+func TestSearchUnknownResidues(t *testing.T) {
+	// The alphabet has no 'X' or 'N', so 'U' in the database sequence
+	// cannot be scored. It must not be aligned, and must not cause a panic.
+	const bases = "ACGT"
+	acgt := SubstMatrix{
+		NewAlphabet('A', 'C', 'G', 'T'),
+		[][]int{{5, -4, -4, -4}, {-4, 5, -4, -4}, {-4, -4, 5, -4},
+			{-4, -4, -4, 5}},
+	}
+	rng := rand.New(rand.NewSource(3))
+	query := randomResidues(rng, 100, bases)
+	subject := randomResidues(rng, 30, bases)
+	subject = append(subject, query[:50]...)
+	subject = append(subject, 'U')
+	subject = append(subject, query[51:]...)
+	db := NewSearchDB([]Sequence{{Name: "u", Residues: subject}},
+		acgt.Alphabet, 3)
+
+	conf := NewSearchConfig()
+	conf.Align = NewAlignConfig(acgt)
+	conf.Stats = KarlinAltschul{Lambda: 0.192, K: 0.176, H: 0.357}
+	hits := conf.Search(db, query)
+	if len(hits) != 1 {
+		t.Fatalf("Expected 1 hit but got %d: %+v", len(hits), hits)
+	}
+	aligned := hits[0].Alignment
+	for i := range aligned.A {
+		if aligned.A[i] == 'U' && aligned.B[i] != '-' {
+			t.Fatalf("Expected 'U' to be unaligned, but got\n%s\n%s",
+				aligned.A, aligned.B)
+		}
+	}
+	if aligned.Score < 5*50 {
+		t.Fatalf("Expected a score of at least %d but got %d.",
+			5*50, aligned.Score)
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	const aminos = "ACDEFGHIKLMNPQRSTVWY"
+	rng := rand.New(rand.NewSource(1))
+	query := randomResidues(rng, 300, aminos)
+	seqs := make([]Sequence, 2000)
+	for i := range seqs {
+		seqs[i].Residues = randomResidues(rng, 300, aminos)
+	}
+	db := NewSearchDB(seqs, SubstBlosum62.Alphabet, 3)
+	conf := NewSearchConfig()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conf.Search(db, query)
+	}
+}
@@ -8,6 +8,14 @@ import (
 type Alignment struct {
 	A []Residue // Reference
 	B []Residue // Query
+
+	// The score of the alignment and the region of each input sequence
+	// covered by the alignment. Start positions are inclusive and end
+	// positions are exclusive, and both are 0-indexed. (For a global
+	// alignment, the region always covers the entire sequence.)
+	Score        int
+	AStart, AEnd int
+	BStart, BEnd int
 }
 
 func newAlignment(length int) Alignment {
@@ -21,7 +29,36 @@ func newAlignment(length int) Alignment {
 // of sequences. A function `subst` should return alignment scores for
 // pairs of residues. This package provides some functions suitable for
 // this purpose, e.g., MatBlosum62, MatDNA, MatRNA, etc.
+//
+// The score of the alignment is set in the Alignment returned.
+//
+// Residues that aren't in the alphabet of the substitution matrix are scored
+// as described by SubstMatrix.ScoreIndex. If a residue cannot be scored (or
+// the alphabet has no gap character '-'), then NeedlemanWunsch panics with a
+// message describing the problem. Use SubstMatrix.NeedlemanWunsch to get an
+// error instead.
 func NeedlemanWunsch(A, B []Residue, subst SubstMatrix) Alignment {
+	idx, gapPenalty := subst.mustPairIndex("NeedlemanWunsch", A, B)
+	return needlemanWunsch(A, B, subst, idx, gapPenalty)
+}
+
+// NeedlemanWunsch is the same as the NeedlemanWunsch function, except it
+// returns an error if a residue cannot be scored or the alphabet of the
+// substitution matrix has no gap character.
+func (m SubstMatrix) NeedlemanWunsch(A, B []Residue) (Alignment, error) {
+	idx, gapPenalty, err := m.pairIndex(A, B)
+	if err != nil {
+		return Alignment{}, err
+	}
+	return needlemanWunsch(A, B, m, idx, gapPenalty), nil
+}
+
+func needlemanWunsch(
+	A, B []Residue,
+	subst SubstMatrix,
+	idx AlphabetIndex,
+	gapPenalty int,
+) Alignment {
 	// This implementation is taken from the "Needleman-Wunsch_algorithm"
 	// Wikipedia article.
 	// rows correspond to residues in A
@@ -31,9 +68,7 @@ func NeedlemanWunsch(A, B []Residue, subst SubstMatrix) Alignment {
 	var p int
 	r, c := len(A)+1, len(B)+1
 	matrix := make([]int, r*c)
-	idx := subst.Alphabet.Index()
 	sub := subst.Scores
-	gapPenalty := sub[idx['-']][idx['-']]
 
 	// Compute the matrix.
 	for i := 0; i < r; i++ {
@@ -89,11 +124,184 @@ func NeedlemanWunsch(A, B []Residue, subst SubstMatrix) Alignment {
 	}
 
 	// Since we built the alignment in backwards, we must reverse the alignment.
+	reverseAlignment(aligned)
+
+	aligned.Score = matrix[r*c-1]
+	aligned.AStart, aligned.AEnd = 0, len(A)
+	aligned.BStart, aligned.BEnd = 0, len(B)
+	return aligned
+}
+
+// Len returns the number of columns in the alignment.
+func (a Alignment) Len() int {
+	return len(a.A)
+}
+
+// Identities returns the number of columns in the alignment with identical
+// residues. Case is ignored.
+func (a Alignment) Identities() int {
+	count := 0
+	for i := range a.A {
+		if a.A[i] != '-' && sameResidue(a.A[i], a.B[i]) {
+			count++
+		}
+	}
+	return count
+}
+
+// sameResidue returns true if the residues are equal, ignoring case.
+func sameResidue(a, b Residue) bool {
+	return residueUpper(a) == residueUpper(b)
+}
+
+// Identity returns the fraction of columns in the alignment with identical
+// residues. An empty alignment has an identity of 0.
+func (a Alignment) Identity() float64 {
+	if a.Len() == 0 {
+		return 0
+	}
+	return float64(a.Identities()) / float64(a.Len())
+}
+
+// Positives returns the number of columns in the alignment whose pair of
+// residues has a positive score in the given substitution matrix. Columns
+// with a gap are never positive.
+func (a Alignment) Positives(subst SubstMatrix) int {
+	idx := subst.mustScoreIndex("Alignment.Positives", a.A, a.B)
+	count := 0
+	for i := range a.A {
+		if a.A[i] == '-' || a.B[i] == '-' {
+			continue
+		}
+		if subst.Scores[idx[a.A[i]]][idx[a.B[i]]] > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// Similarity returns the fraction of columns in the alignment whose pair of
+// residues has a positive score in the given substitution matrix. An empty
+// alignment has a similarity of 0.
+func (a Alignment) Similarity(subst SubstMatrix) float64 {
+	if a.Len() == 0 {
+		return 0
+	}
+	return float64(a.Positives(subst)) / float64(a.Len())
+}
+
+// Gaps returns the number of columns in the alignment with a gap in either
+// sequence.
+func (a Alignment) Gaps() int {
+	count := 0
+	for i := range a.A {
+		if a.A[i] == '-' || a.B[i] == '-' {
+			count++
+		}
+	}
+	return count
+}
+
+// SmithWaterman performs the Smith-Waterman local sequence alignment
+// algorithm on a pair of sequences. Like NeedlemanWunsch, the gap penalty is
+// taken from the score of aligning '-' with '-' in the substitution matrix.
+//
+// Only the highest scoring aligned region is returned. The score of the
+// alignment and the positions of the region in A and B are set in the
+// Alignment returned. If no pair of residues has a positive score, then the
+// alignment returned is empty.
+//
+// Residues that aren't in the alphabet of the substitution matrix are handled
+// in the same way as NeedlemanWunsch. Use SubstMatrix.SmithWaterman to get an
+// error instead of a panic when a residue cannot be scored.
+func SmithWaterman(A, B []Residue, subst SubstMatrix) Alignment {
+	idx, gapPenalty := subst.mustPairIndex("SmithWaterman", A, B)
+	return smithWaterman(A, B, subst, idx, gapPenalty)
+}
+
+// SmithWaterman is the same as the SmithWaterman function, except it returns
+// an error if a residue cannot be scored or the alphabet of the substitution
+// matrix has no gap character.
+func (m SubstMatrix) SmithWaterman(A, B []Residue) (Alignment, error) {
+	idx, gapPenalty, err := m.pairIndex(A, B)
+	if err != nil {
+		return Alignment{}, err
+	}
+	return smithWaterman(A, B, m, idx, gapPenalty), nil
+}
+
+func smithWaterman(
+	A, B []Residue,
+	subst SubstMatrix,
+	idx AlphabetIndex,
+	gapPenalty int,
+) Alignment {
+	// rows correspond to residues in A
+	// cols correspond to residues in B
+
+	// Initialization. The first row and column are all zeroes, which is
+	// what make gives us.
+	var p int
+	r, c := len(A)+1, len(B)+1
+	matrix := make([]int, r*c)
+	sub := subst.Scores
+
+	// Compute the matrix while keeping track of the best cell.
+	var diag, sleft, sup, best, besti, bestj int
+	var subsub []int
+	for i := 1; i < r; i++ {
+		subsub = sub[idx[A[i-1]]]
+		for j := 1; j < c; j++ {
+			p = i*c + j
+			diag = matrix[p-c-1] + subsub[idx[B[j-1]]]
+			sup, sleft = matrix[p-c]+gapPenalty, matrix[p-1]+gapPenalty
+			matrix[p] = max(0, max3(diag, sup, sleft))
+			if matrix[p] > best {
+				best, besti, bestj = matrix[p], i, j
+			}
+		}
+	}
+
+	// Now trace an optimal path through the matrix starting at the best cell
+	// and stopping once we hit a zero.
+	aligned := newAlignment(max(besti, bestj))
+	i, j := besti, bestj
+	for i > 0 && j > 0 && matrix[i*c+j] > 0 {
+		p = i*c + j
+		switch {
+		case matrix[p] == matrix[p-c-1]+sub[idx[A[i-1]]][idx[B[j-1]]]:
+			aligned.A = append(aligned.A, A[i-1])
+			aligned.B = append(aligned.B, B[j-1])
+			i--
+			j--
+		case matrix[p] == matrix[p-c]+gapPenalty:
+			aligned.A = append(aligned.A, A[i-1])
+			aligned.B = append(aligned.B, '-')
+			i--
+		case matrix[p] == matrix[p-1]+gapPenalty:
+			aligned.A = append(aligned.A, '-')
+			aligned.B = append(aligned.B, B[j-1])
+			j--
+		default:
+			panic(fmt.Sprintf("BUG in SmithWaterman: No path at (%d, %d)",
+				i, j))
+		}
+	}
+	reverseAlignment(aligned)
+
+	aligned.Score = best
+	aligned.AStart, aligned.AEnd = i, besti
+	aligned.BStart, aligned.BEnd = j, bestj
+	return aligned
+}
+
+// reverseAlignment reverses an alignment in place. (Alignments are usually
+// built backwards during traceback.)
+func reverseAlignment(aligned Alignment) {
 	for i, j := 0, len(aligned.A)-1; i < j; i, j = i+1, j-1 {
 		aligned.A[i], aligned.A[j] = aligned.A[j], aligned.A[i]
 		aligned.B[i], aligned.B[j] = aligned.B[j], aligned.B[i]
 	}
-	return aligned
 }
 
 func max(a, b int) int {
@@ -103,6 +311,13 @@ func max(a, b int) int {
 	return b
 }
 
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 func max3(a, b, c int) int {
 	switch {
 	case a > b && a > c:
@@ -0,0 +1,478 @@
+package seq
+
+import (
+	"fmt"
+	"math"
+)
+
+// AlignMode specifies the kind of pairwise alignment computed by an
+// AlignConfig.
+type AlignMode int
+
+const (
+	// AlignGlobal aligns the entirety of both sequences.
+	AlignGlobal AlignMode = iota
+
+	// AlignLocal aligns only the highest scoring pair of subsequences.
+	AlignLocal
+
+	// AlignSemiGlobal aligns the entirety of B to a region of A. That is,
+	// gaps at either end of B are free. This is useful for aligning a short
+	// sequence (like a domain) to a longer one. (This is sometimes called
+	// "glocal" alignment.)
+	AlignSemiGlobal
+
+	// AlignOverlap aligns the sequences without penalizing gaps at either
+	// end of either sequence. This is useful for finding overlaps between
+	// the end of one sequence and the start of another.
+	AlignOverlap
+)
+
+// EndGaps specifies which terminal gaps are free in a global alignment.
+// Each field corresponds to one end of one sequence. When set, residues at
+// that end of that sequence may be left unaligned without penalty.
+//
+// For example, StartA means that leading residues of A may be aligned to a
+// (free) gap, and EndB means that trailing residues of B may be aligned to a
+// (free) gap.
+type EndGaps struct {
+	StartA, EndA bool
+	StartB, EndB bool
+}
+
+// AlignConfig describes a pairwise alignment with affine gap penalties, which
+// is computed with Gotoh's three matrix variant of the usual dynamic
+// programming algorithms.
+//
+// Unlike NeedlemanWunsch and SmithWaterman, gap penalties are not read from
+// the substitution matrix. (So the alphabet of the substitution matrix does
+// not need a gap character.)
+type AlignConfig struct {
+	Subst SubstMatrix
+
+	// The costs of opening and extending a gap. A gap of length k has a
+	// penalty of GapOpen + k*GapExtend. (This is the convention used by
+	// BLAST.) Both costs should be non-negative.
+	GapOpen, GapExtend int
+
+	Mode AlignMode
+
+	// Terminal gaps that are free, in addition to the ones implied by the
+	// mode. This is ignored for local alignment.
+	FreeEnds EndGaps
+}
+
+// endGaps returns the free terminal gaps implied by the mode and FreeEnds.
+func (c AlignConfig) endGaps() EndGaps {
+	ends := c.FreeEnds
+	switch c.Mode {
+	case AlignLocal:
+		return EndGaps{}
+	case AlignSemiGlobal:
+		ends.StartA, ends.EndA = true, true
+	case AlignOverlap:
+		ends = EndGaps{true, true, true, true}
+	}
+	return ends
+}
+
+// NewAlignConfig returns a configuration for global alignment with the given
+// substitution matrix and the gap costs usually used with BLOSUM62: a gap
+// open cost of 11 and a gap extension cost of 1.
+func NewAlignConfig(subst SubstMatrix) AlignConfig {
+	return AlignConfig{
+		Subst:     subst,
+		GapOpen:   11,
+		GapExtend: 1,
+		Mode:      AlignGlobal,
+	}
+}
+
+// negInf is used as the score of impossible cells. It is small enough to
+// never be chosen, but large enough that adding penalties to it won't
+// overflow.
+const negInf = math.MinInt32 / 2
+
+// affineTable holds the three dynamic programming matrices used in Gotoh's
+// algorithm. m corresponds to alignments ending with a pair of aligned
+// residues, x corresponds to alignments ending with a residue in A aligned
+// to a gap and y corresponds to alignments ending with a residue in B aligned
+// to a gap.
+//
+// A table may either be full or banded. A full table stores every column of
+// every row. A banded table only stores cells (i, j) where j - i is in the
+// range [base, base + width).
+type affineTable struct {
+	m, x, y []int
+	rows    int
+	cols    int
+
+	// The number of cells stored in each row, and the column of the first
+	// cell stored in row i is i*shift + base.
+	width, shift, base int
+}
+
+func newAffineTable(rows, cols int) *affineTable {
+	return newAffineTableBand(rows, cols, cols, 0, 0)
+}
+
+func newAffineTableBand(rows, cols, width, shift, base int) *affineTable {
+	t := new(affineTable)
+	t.resize(rows, cols, width, shift, base)
+	return t
+}
+
+// resize changes the shape of the table, reusing its memory when possible.
+// The contents of the table are not preserved, but every cell is written by
+// AlignConfig.align before it is read, so the table need not be cleared.
+func (t *affineTable) resize(rows, cols, width, shift, base int) {
+	if n := rows * width; n > cap(t.m) {
+		t.m, t.x, t.y = make([]int, n), make([]int, n), make([]int, n)
+	} else {
+		t.m, t.x, t.y = t.m[:n], t.x[:n], t.y[:n]
+	}
+	t.rows, t.cols = rows, cols
+	t.width, t.shift, t.base = width, shift, base
+}
+
+// AlignTable is a dynamic programming table that can be reused across calls
+// to AlignConfig.AlignMem. It grows as needed to fit the largest pair of
+// sequences aligned with it.
+type AlignTable struct {
+	t affineTable
+}
+
+// NewAlignTable returns an empty table for use with AlignConfig.AlignMem.
+func NewAlignTable() *AlignTable {
+	return new(AlignTable)
+}
+
+// index returns the index of the cell (i, j), or -1 if the cell isn't in
+// the table.
+func (t *affineTable) index(i, j int) int {
+	k := j - i*t.shift - t.base
+	if i < 0 || j < 0 || j >= t.cols || k < 0 || k >= t.width {
+		return -1
+	}
+	return i*t.width + k
+}
+
+// get returns the value of the cell (i, j) in the given matrix, or negInf if
+// the cell isn't in the table.
+func (t *affineTable) get(matrix []int, i, j int) int {
+	if p := t.index(i, j); p >= 0 {
+		return matrix[p]
+	}
+	return negInf
+}
+
+// span returns the first and last columns stored in row i.
+func (t *affineTable) span(i int) (int, int) {
+	lo := i*t.shift + t.base
+	return max(0, lo), min(t.cols-1, lo+t.width-1)
+}
+
+// Align computes the optimal alignment of A and B according to the
+// configuration. The score and the region of each sequence covered by the
+// alignment are set in the Alignment returned.
+//
+// Free terminal gaps are not included in the alignment returned. Instead,
+// the region of each sequence covered by the alignment excludes them.
+//
+// Residues that aren't in the alphabet of the substitution matrix are scored
+// as described by SubstMatrix.ScoreIndex. If a residue cannot be scored, then
+// Align panics with a message describing the offending residue. (A gap
+// character is not required.)
+func (c AlignConfig) Align(A, B []Residue) Alignment {
+	return c.align(A, B, newAffineTable(len(A)+1, len(B)+1))
+}
+
+// AlignMem is the same as Align, except it reuses the memory of the dynamic
+// programming table given instead of allocating a new one, which makes it
+// faster when aligning many pairs of sequences.
+//
+// Note that the caller must ensure that only one goroutine is calling
+// AlignMem with the same table.
+func (c AlignConfig) AlignMem(A, B []Residue, table *AlignTable) Alignment {
+	table.t.resize(len(A)+1, len(B)+1, len(B)+1, 0, 0)
+	return c.align(A, B, &table.t)
+}
+
+// AlignBanded is like Align, except only cells of the dynamic programming
+// table within a band around the main diagonal are computed. This makes
+// alignment of similar sequences much faster, but the alignment returned is
+// only optimal if an optimal alignment lies within the band.
+//
+// The band includes every cell (i, j) such that j - i is within width of
+// the range [min(0, len(B)-len(A)), max(0, len(B)-len(A))]. (So that a band
+// of width 0 still permits a global alignment.) A negative width is treated
+// as zero.
+func (c AlignConfig) AlignBanded(A, B []Residue, width int) Alignment {
+	if width < 0 {
+		width = 0
+	}
+	diff := len(B) - len(A)
+	lo, hi := min(0, diff)-width, max(0, diff)+width
+	t := newAffineTableBand(len(A)+1, len(B)+1, hi-lo+1, 1, lo)
+	return c.align(A, B, t)
+}
+
+// AlignBandedAuto repeatedly calls AlignBanded, starting with the given band
+// width and doubling it until the score of the alignment stops changing (or
+// until the band covers the entire table). The last alignment computed is
+// returned.
+func (c AlignConfig) AlignBandedAuto(A, B []Residue, width int) Alignment {
+	if width < 1 {
+		width = 1
+	}
+	aligned := c.AlignBanded(A, B, width)
+	for width < len(A)+len(B) {
+		width *= 2
+		wider := c.AlignBanded(A, B, width)
+		if wider.Score == aligned.Score {
+			break
+		}
+		aligned = wider
+	}
+	return aligned
+}
+
+func (c AlignConfig) align(A, B []Residue, t *affineTable) Alignment {
+	idx := c.Subst.mustScoreIndex("AlignConfig.Align", A, B)
+	return c.alignIndex(A, B, t, idx)
+}
+
+// alignIndex is like align, except residues are scored with the index given,
+// which must map every residue of A and B to a row of c.Subst.Scores.
+//
+// The band of the table need not include the cell (0, 0), but only local
+// alignments may be computed with such a band.
+func (c AlignConfig) alignIndex(
+	A, B []Residue,
+	t *affineTable,
+	idx AlphabetIndex,
+) Alignment {
+	// rows correspond to residues in A
+	// cols correspond to residues in B
+	sub := c.Subst.Scores
+	open, ext := c.GapOpen+c.GapExtend, c.GapExtend
+	local := c.Mode == AlignLocal
+	ends := c.endGaps()
+
+	// Initialization of the first row and column.
+	for i := 0; i < t.rows; i++ {
+		p := t.index(i, 0)
+		if p < 0 {
+			continue
+		}
+		t.m[p], t.y[p] = negInf, negInf
+		switch {
+		case i == 0:
+			t.m[p], t.x[p] = 0, negInf
+		case local:
+			t.x[p] = negInf
+		case ends.StartA:
+			t.x[p] = 0
+		default:
+			t.x[p] = -c.GapOpen - i*ext
+		}
+	}
+	for j := 1; j < t.cols; j++ {
+		p := t.index(0, j)
+		if p < 0 {
+			continue
+		}
+		t.m[p], t.x[p] = negInf, negInf
+		switch {
+		case local:
+			t.y[p] = negInf
+		case ends.StartB:
+			t.y[p] = 0
+		default:
+			t.y[p] = -c.GapOpen - j*ext
+		}
+	}
+
+	// Compute the matrices. For local alignment, keep track of the best
+	// match cell.
+	//
+	// The indices of neighboring cells are computed directly, since this is
+	// the hot loop. Neighbors outside of the table have a score of negInf.
+	var p, prev, best, besti, bestj int
+	var dm, dx, dy, um, ux, uy, lm, lx, ly int
+	var subsub []int
+	best = negInf
+	for i := 1; i < t.rows; i++ {
+		subsub = sub[idx[A[i-1]]]
+		lo, hi := t.span(i)
+		plo, phi := t.span(i - 1)
+		for j := max(1, lo); j <= hi; j++ {
+			p = t.index(i, j)
+
+			dm, dx, dy = negInf, negInf, negInf
+			if j-1 >= plo && j-1 <= phi {
+				d := p - t.width - 1 + t.shift
+				dm, dx, dy = t.m[d], t.x[d], t.y[d]
+			}
+			um, ux, uy = negInf, negInf, negInf
+			if j >= plo && j <= phi {
+				u := p - t.width + t.shift
+				um, ux, uy = t.m[u], t.x[u], t.y[u]
+			}
+			lm, lx, ly = negInf, negInf, negInf
+			if j-1 >= lo {
+				lm, lx, ly = t.m[p-1], t.x[p-1], t.y[p-1]
+			}
+
+			prev = max3(dm, dx, dy)
+			if local && prev < 0 {
+				prev = 0
+			}
+			t.m[p] = prev + subsub[idx[B[j-1]]]
+			t.x[p] = max3(um-open, ux-ext, uy-open)
+			t.y[p] = max3(lm-open, ly-ext, lx-open)
+			if local && t.m[p] > best {
+				best, besti, bestj = t.m[p], i, j
+			}
+		}
+	}
+
+	// Find the cell and state to start the traceback from.
+	var state HMMState
+	i, j := t.rows-1, t.cols-1
+	if local {
+		if best <= 0 {
+			return newAlignment(0)
+		}
+		i, j, state = besti, bestj, Match
+	} else {
+		best = negInf
+		consider := func(ci, cj int) {
+			p := t.index(ci, cj)
+			if p < 0 {
+				return
+			}
+			for _, cand := range []struct {
+				score int
+				state HMMState
+			}{{t.m[p], Match}, {t.x[p], Deletion}, {t.y[p], Insertion}} {
+				if cand.score > best {
+					best, i, j, state = cand.score, ci, cj, cand.state
+				}
+			}
+		}
+		consider(t.rows-1, t.cols-1)
+		if ends.EndA {
+			for ci := 0; ci < t.rows; ci++ {
+				consider(ci, t.cols-1)
+			}
+		}
+		if ends.EndB {
+			for cj := 0; cj < t.cols; cj++ {
+				consider(t.rows-1, cj)
+			}
+		}
+	}
+	aligned := c.traceback(t, idx, A, B, i, j, state)
+	aligned.Score = best
+	return aligned
+}
+
+// traceback follows an optimal path through the table starting at the cell
+// (i, j) in the given state. Match corresponds to the m matrix, Deletion
+// corresponds to the x matrix and Insertion corresponds to the y matrix.
+func (c AlignConfig) traceback(
+	t *affineTable,
+	idx AlphabetIndex,
+	A, B []Residue,
+	i, j int,
+	state HMMState,
+) Alignment {
+	sub := c.Subst.Scores
+	open, ext := c.GapOpen+c.GapExtend, c.GapExtend
+	local := c.Mode == AlignLocal
+	ends := c.endGaps()
+
+	aligned := newAlignment(max(i, j))
+	aend, bend := i, j
+	nopath := func() {
+		panic(fmt.Sprintf("BUG in AlignConfig.Align: No path at (%d, %d)",
+			i, j))
+	}
+TRACE:
+	for i > 0 || j > 0 {
+		// Once we've reached a free terminal gap, the alignment starts here.
+		if (j == 0 && ends.StartA) || (i == 0 && ends.StartB) {
+			break
+		}
+
+		p := t.index(i, j)
+		if p < 0 {
+			nopath()
+		}
+		switch state {
+		case Match:
+			if i == 0 || j == 0 {
+				nopath()
+			}
+			prev := t.m[p] - sub[idx[A[i-1]]][idx[B[j-1]]]
+			aligned.A = append(aligned.A, A[i-1])
+			aligned.B = append(aligned.B, B[j-1])
+			i--
+			j--
+
+			// In local alignment, a zero means the alignment starts here.
+			if local && prev == 0 {
+				break TRACE
+			}
+			switch prev {
+			case t.get(t.m, i, j):
+				state = Match
+			case t.get(t.x, i, j):
+				state = Deletion
+			case t.get(t.y, i, j):
+				state = Insertion
+			default:
+				nopath()
+			}
+		case Deletion:
+			if i == 0 {
+				nopath()
+			}
+			aligned.A = append(aligned.A, A[i-1])
+			aligned.B = append(aligned.B, '-')
+			i--
+			switch t.x[p] {
+			case t.get(t.m, i, j) - open:
+				state = Match
+			case t.get(t.x, i, j) - ext:
+				state = Deletion
+			case t.get(t.y, i, j) - open:
+				state = Insertion
+			default:
+				nopath()
+			}
+		case Insertion:
+			if j == 0 {
+				nopath()
+			}
+			aligned.A = append(aligned.A, '-')
+			aligned.B = append(aligned.B, B[j-1])
+			j--
+			switch t.y[p] {
+			case t.get(t.m, i, j) - open:
+				state = Match
+			case t.get(t.y, i, j) - ext:
+				state = Insertion
+			case t.get(t.x, i, j) - open:
+				state = Deletion
+			default:
+				nopath()
+			}
+		}
+	}
+	reverseAlignment(aligned)
+	aligned.AStart, aligned.AEnd = i, aend
+	aligned.BStart, aligned.BEnd = j, bend
+	return aligned
+}
@@ -0,0 +1,338 @@
+package seq
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+type affineTest struct {
+	seq1, seq2   string
+	out1, out2   string
+	mode         AlignMode
+	score        int
+	astart, aend int
+	bstart, bend int
+}
+
+var affineTests = []affineTest{
+	{
+		"GHIKLMNPQRSTVW", "GHIKLMNSTVW",
+		"GHIKLMNPQRSTVW", "GHIKLMN---STVW",
+		AlignGlobal, 48, 0, 14, 0, 11,
+	},
+	{
+		"HIKLMN", "HIKWLMN",
+		"HIK-LMN", "HIKWLMN",
+		AlignGlobal, 20, 0, 6, 0, 7,
+	},
+	{
+		"ABCD", "ABCD",
+		"ABCD", "ABCD",
+		AlignGlobal, 23, 0, 4, 0, 4,
+	},
+	{
+		"PPPPGHIKLMNPQRSTVWPPPP", "CCGHIKLMNSTVWCC",
+		"GHIKLMNPQRSTVW", "GHIKLMN---STVW",
+		AlignLocal, 48, 4, 18, 2, 13,
+	},
+	{
+		"AAAA", "WWWW",
+		"", "",
+		AlignLocal, 0, 0, 0, 0, 0,
+	},
+	{
+		"AAAAGHIKLMNPQRSTVWAAAA", "GHIKLMNSTVW",
+		"GHIKLMNPQRSTVW", "GHIKLMN---STVW",
+		AlignSemiGlobal, 48, 4, 18, 0, 11,
+	},
+	{
+		"WWWWWGHIKLMN", "GHIKLMNCCCCC",
+		"GHIKLMN", "GHIKLMN",
+		AlignOverlap, 38, 5, 12, 0, 7,
+	},
+	{
+		"GHIKLMN", "WWWWWGHIKLMN",
+		"GHIKLMN", "GHIKLMN",
+		AlignOverlap, 38, 0, 7, 5, 12,
+	},
+}
+
+func TestAlignAffine(t *testing.T) {
+	for _, test := range affineTests {
+		conf := NewAlignConfig(SubstBlosum62)
+		conf.Mode = test.mode
+		testAlignment(t, test, conf.Align(stringToSeq(test.seq1),
+			stringToSeq(test.seq2)))
+	}
+}
+
+func TestAlignFreeEnds(t *testing.T) {
+	tests := []struct {
+		test affineTest
+		ends EndGaps
+	}{
+		{
+			affineTest{
+				"GHIKLMNPPPP", "GHIKLMN",
+				"GHIKLMN", "GHIKLMN",
+				AlignGlobal, 38, 0, 7, 0, 7,
+			},
+			EndGaps{EndA: true},
+		},
+		{
+			affineTest{
+				"GHIKLMNPPPP", "GHIKLMN",
+				"GHIKLMNPPPP", "GHIKLMN----",
+				AlignGlobal, 23, 0, 11, 0, 7,
+			},
+			EndGaps{StartA: true, EndB: true},
+		},
+		{
+			affineTest{
+				"GHIKLMN", "PPGHIKLMN",
+				"GHIKLMN", "GHIKLMN",
+				AlignGlobal, 38, 0, 7, 2, 9,
+			},
+			EndGaps{StartB: true},
+		},
+	}
+	for _, test := range tests {
+		conf := NewAlignConfig(SubstBlosum62)
+		conf.FreeEnds = test.ends
+		testAlignment(t, test.test, conf.Align(stringToSeq(test.test.seq1),
+			stringToSeq(test.test.seq2)))
+	}
+}
+
+func TestAlignBanded(t *testing.T) {
+	modes := []AlignMode{
+		AlignGlobal, AlignLocal, AlignSemiGlobal, AlignOverlap,
+	}
+	for _, test := range affineTests {
+		s1, s2 := stringToSeq(test.seq1), stringToSeq(test.seq2)
+		for _, mode := range modes {
+			conf := NewAlignConfig(SubstBlosum62)
+			conf.Mode = mode
+			full := conf.Align(s1, s2)
+			banded := conf.AlignBanded(s1, s2, len(s1)+len(s2))
+			if full.Score != banded.Score ||
+				resString(full.A) != resString(banded.A) ||
+				resString(full.B) != resString(banded.B) {
+				t.Fatalf("Banded alignment with a full band differs from "+
+					"the full alignment:\n%s\n%s\n(%d) versus\n%s\n%s\n(%d)",
+					full.A, full.B, full.Score,
+					banded.A, banded.B, banded.Score)
+			}
+		}
+	}
+
+	// Similar sequences only need a narrow band.
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		s1 := randomResidues(rng, 100+rng.Intn(100), "ACDEFGHIKLMNPQRSTVWY")
+		s2 := mutate(rng, s1, 5, "ACDEFGHIKLMNPQRSTVWY")
+		conf := NewAlignConfig(SubstBlosum62)
+		full := conf.Align(s1, s2)
+		for _, banded := range []Alignment{
+			conf.AlignBanded(s1, s2, 10),
+			conf.AlignBandedAuto(s1, s2, 1),
+		} {
+			testAffineConsistent(t, conf, s1, s2, banded)
+			if full.Score != banded.Score {
+				t.Fatalf("Banded alignment score %d differs from full "+
+					"alignment score %d.", banded.Score, full.Score)
+			}
+		}
+	}
+}
+
+func BenchmarkAlignAffine(b *testing.B) {
+	test := alignTests[0]
+	s1, s2 := stringToSeq(test.seq1), stringToSeq(test.seq2)
+	conf := NewAlignConfig(SubstBlosum62)
+	for i := 0; i < b.N; i++ {
+		conf.Align(s1, s2)
+	}
+}
+
+func BenchmarkAlignBanded(b *testing.B) {
+	test := alignTests[0]
+	s1, s2 := stringToSeq(test.seq1), stringToSeq(test.seq2)
+	conf := NewAlignConfig(SubstBlosum62)
+	for i := 0; i < b.N; i++ {
+		conf.AlignBanded(s1, s2, 16)
+	}
+}
+
+// mutate returns a copy of rs with n random substitutions, insertions or
+// deletions.
+func mutate(rng *rand.Rand, rs []Residue, n int, alphabet string) []Residue {
+	mutated := make([]Residue, len(rs))
+	copy(mutated, rs)
+	for i := 0; i < n; i++ {
+		pos := rng.Intn(len(mutated))
+		r := Residue(alphabet[rng.Intn(len(alphabet))])
+		switch rng.Intn(3) {
+		case 0:
+			mutated[pos] = r
+		case 1:
+			mutated = append(mutated[:pos],
+				append([]Residue{r}, mutated[pos:]...)...)
+		case 2:
+			mutated = append(mutated[:pos], mutated[pos+1:]...)
+		}
+	}
+	return mutated
+}
+
+func TestAlignAffineGaps(t *testing.T) {
+	// With a large gap open cost, a single long gap is preferred to many
+	// short gaps.
+	s1, s2 := stringToSeq("GHIKLMNPQRSTVW"), stringToSeq("GHIKLMNSTVW")
+	conf := NewAlignConfig(SubstBlosum62)
+	aligned := conf.Align(s1, s2)
+	if gaps := countGapOpens(aligned.B); gaps != 1 {
+		t.Fatalf("Expected 1 gap but got %d in %s.", gaps, aligned.B)
+	}
+}
+
+func TestAlignAffineRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		s1 := randomResidues(rng, 1+rng.Intn(40), "ACDEFGHIKLMNPQRSTVWY")
+		s2 := randomResidues(rng, 1+rng.Intn(40), "ACDEFGHIKLMNPQRSTVWY")
+		modes := []AlignMode{
+			AlignGlobal, AlignLocal, AlignSemiGlobal, AlignOverlap,
+		}
+		for _, mode := range modes {
+			conf := NewAlignConfig(SubstBlosum62)
+			conf.Mode = mode
+			aligned := conf.Align(s1, s2)
+			testAffineConsistent(t, conf, s1, s2, aligned)
+
+			coversB := aligned.BStart == 0 && aligned.BEnd == len(s2)
+			coversA := aligned.AStart == 0 && aligned.AEnd == len(s1)
+			if mode == AlignSemiGlobal && !coversB {
+				t.Fatalf("Semi-global alignment must cover all of B.")
+			}
+			if mode == AlignGlobal && (!coversA || !coversB) {
+				t.Fatalf("Global alignment must cover both sequences.")
+			}
+		}
+	}
+}
+
+// testAffineConsistent checks that the score of an alignment agrees with the
+// residues in the alignment, and that the alignment covers the region of each
+// sequence it claims to.
+func testAffineConsistent(
+	t *testing.T,
+	conf AlignConfig,
+	s1, s2 []Residue,
+	aligned Alignment,
+) {
+	if got := ungap(aligned.A); got != resString(s1[aligned.AStart:aligned.AEnd]) {
+		t.Fatalf("Alignment %s does not cover A[%d:%d] of %s.",
+			aligned.A, aligned.AStart, aligned.AEnd, s1)
+	}
+	if got := ungap(aligned.B); got != resString(s2[aligned.BStart:aligned.BEnd]) {
+		t.Fatalf("Alignment %s does not cover B[%d:%d] of %s.",
+			aligned.B, aligned.BStart, aligned.BEnd, s2)
+	}
+	if score := affineScore(conf, aligned); score != aligned.Score {
+		t.Fatalf("Alignment\n%s\n%s\nhas score %d but reported %d.",
+			aligned.A, aligned.B, score, aligned.Score)
+	}
+}
+
+func affineScore(conf AlignConfig, aligned Alignment) int {
+	idx := conf.Subst.Alphabet.Index()
+	score := 0
+	for i := range aligned.A {
+		a, b := aligned.A[i], aligned.B[i]
+		switch {
+		case a == '-':
+			score -= conf.GapExtend
+			if i == 0 || aligned.A[i-1] != '-' {
+				score -= conf.GapOpen
+			}
+		case b == '-':
+			score -= conf.GapExtend
+			if i == 0 || aligned.B[i-1] != '-' {
+				score -= conf.GapOpen
+			}
+		default:
+			score += conf.Subst.Scores[idx[a]][idx[b]]
+		}
+	}
+	return score
+}
+
+func randomResidues(rng *rand.Rand, n int, alphabet string) []Residue {
+	rs := make([]Residue, n)
+	for i := range rs {
+		rs[i] = Residue(alphabet[rng.Intn(len(alphabet))])
+	}
+	return rs
+}
+
+func ungap(rs []Residue) string {
+	bs := make([]byte, 0, len(rs))
+	for _, r := range rs {
+		if r != '-' {
+			bs = append(bs, byte(r))
+		}
+	}
+	return string(bs)
+}
+
+func resString(rs []Residue) string {
+	return fmt.Sprintf("%s", rs)
+}
+
+func testAlignment(t *testing.T, test affineTest, aligned Alignment) {
+	sout1 := fmt.Sprintf("%s", aligned.A)
+	sout2 := fmt.Sprintf("%s", aligned.B)
+	if sout1 != test.out1 || sout2 != test.out2 {
+		t.Fatalf("Alignment of\n%s\n%s\nresulted in\n%s\n%s\n"+
+			"but should have been\n%s\n%s",
+			test.seq1, test.seq2, sout1, sout2, test.out1, test.out2)
+	}
+	if aligned.Score != test.score {
+		t.Fatalf("Expected score %d but got %d for\n%s\n%s",
+			test.score, aligned.Score, sout1, sout2)
+	}
+	if aligned.AStart != test.astart || aligned.AEnd != test.aend ||
+		aligned.BStart != test.bstart || aligned.BEnd != test.bend {
+		t.Fatalf("Expected region A[%d:%d], B[%d:%d] but got "+
+			"A[%d:%d], B[%d:%d].",
+			test.astart, test.aend, test.bstart, test.bend,
+			aligned.AStart, aligned.AEnd, aligned.BStart, aligned.BEnd)
+	}
+}
+
+func countGapOpens(rs []Residue) int {
+	opens := 0
+	for i, r := range rs {
+		if r == '-' && (i == 0 || rs[i-1] != '-') {
+			opens++
+		}
+	}
+	return opens
+}
+
+func ExampleAlignConfig() {
+	s1 := NewSequenceString("seq1", "GHIKLMNPQRSTVW")
+	s2 := NewSequenceString("seq2", "GHIKLMNSTVW")
+	conf := NewAlignConfig(SubstBlosum62)
+	aligned := conf.Align(s1.Residues, s2.Residues)
+
+	fmt.Printf("%s\n", aligned.A)
+	fmt.Printf("%s\n", aligned.B)
+	fmt.Printf("score: %d\n", aligned.Score)
+	// Output:
+	// GHIKLMNPQRSTVW
+	// GHIKLMN---STVW
+	// score: 48
+}
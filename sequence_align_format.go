@@ -0,0 +1,90 @@
+package seq
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Format returns a human readable rendering of the alignment, similar to
+// the pairwise output of BLAST or EMBOSS needle. The names given label the
+// A and B sequences, and the substitution matrix is used to mark positive
+// substitutions.
+//
+// The output begins with a header that includes the score, identity,
+// similarity and gaps of the alignment. The alignment is then written in
+// blocks of width columns (60 if width is not positive), where each line is
+// labeled with the positions (1-indexed) of its first and last residue. The
+// line between the sequences marks identical residues with '|', positive
+// substitutions with ':' and all other substitutions with '.'.
+func (a Alignment) Format(
+	nameA, nameB string,
+	subst SubstMatrix,
+	width int,
+) string {
+	if width <= 0 {
+		width = 60
+	}
+	buf := new(bytes.Buffer)
+	pf := func(format string, v ...interface{}) {
+		fmt.Fprintf(buf, format, v...)
+	}
+	pct := func(n int) float64 {
+		if a.Len() == 0 {
+			return 0
+		}
+		return 100 * float64(n) / float64(a.Len())
+	}
+
+	ids, pos, gaps := a.Identities(), a.Positives(subst), a.Gaps()
+	pf("Score = %d\n", a.Score)
+	pf("Identities = %d/%d (%.1f%%), Positives = %d/%d (%.1f%%), "+
+		"Gaps = %d/%d (%.1f%%)\n",
+		ids, a.Len(), pct(ids), pos, a.Len(), pct(pos),
+		gaps, a.Len(), pct(gaps))
+
+	// Compute the middle line in one go.
+	idx := subst.mustScoreIndex("Alignment.Format", a.A, a.B)
+	middle := make([]byte, a.Len())
+	for i := range a.A {
+		switch {
+		case a.A[i] == '-' || a.B[i] == '-':
+			middle[i] = ' '
+		case sameResidue(a.A[i], a.B[i]):
+			middle[i] = '|'
+		case subst.Scores[idx[a.A[i]]][idx[a.B[i]]] > 0:
+			middle[i] = ':'
+		default:
+			middle[i] = '.'
+		}
+	}
+
+	nameWidth := max(len(nameA), len(nameB))
+	posWidth := len(fmt.Sprintf("%d", max(a.AEnd, a.BEnd)))
+	line := func(name string, rs []Residue, start int) int {
+		end := start
+		for _, r := range rs {
+			if r != '-' {
+				end++
+			}
+		}
+		first := start + 1
+		if end == start {
+			first = start
+		}
+		pf("%-*s %*d %s %d\n", nameWidth, name, posWidth, first, rs, end)
+		return end
+	}
+
+	posA, posB := a.AStart, a.BStart
+	for start := 0; start < a.Len(); start += width {
+		end := min(a.Len(), start+width)
+		pf("\n")
+		posA = line(nameA, a.A[start:end], posA)
+		mid := fmt.Sprintf("%*s %s", nameWidth+posWidth+1, "",
+			middle[start:end])
+		pf("%s\n", strings.TrimRight(mid, " "))
+		posB = line(nameB, a.B[start:end], posB)
+	}
+	return buf.String()
+}
@@ -0,0 +1,150 @@
+package seq
+
+// Hirschberg computes an optimal global alignment of a pair of sequences,
+// just like NeedlemanWunsch, except it uses Hirschberg's divide and conquer
+// algorithm. It requires memory proportional to len(A) + len(B) instead of
+// len(A) * len(B), at the cost of roughly doubling the running time. This
+// makes it suitable for aligning very long sequences.
+//
+// The alignment returned is always identical to the one returned by
+// NeedlemanWunsch, including when there is more than one optimal alignment.
+// Residues that aren't in the alphabet of the substitution matrix are handled
+// in the same way as NeedlemanWunsch. Use SubstMatrix.Hirschberg to get an
+// error instead of a panic when a residue cannot be scored.
+func Hirschberg(A, B []Residue, subst SubstMatrix) Alignment {
+	idx, gapPenalty := subst.mustPairIndex("Hirschberg", A, B)
+	return hirschbergAlign(A, B, subst, idx, gapPenalty)
+}
+
+// Hirschberg is the same as the Hirschberg function, except it returns an
+// error if a residue cannot be scored or the alphabet of the substitution
+// matrix has no gap character.
+func (m SubstMatrix) Hirschberg(A, B []Residue) (Alignment, error) {
+	idx, gapPenalty, err := m.pairIndex(A, B)
+	if err != nil {
+		return Alignment{}, err
+	}
+	return hirschbergAlign(A, B, m, idx, gapPenalty), nil
+}
+
+func hirschbergAlign(
+	A, B []Residue,
+	subst SubstMatrix,
+	idx AlphabetIndex,
+	gapPenalty int,
+) Alignment {
+	h := hirschberg{
+		idx:        idx,
+		subst:      subst,
+		gapPenalty: gapPenalty,
+		aligned:    newAlignment(len(A) + len(B)),
+		prev:       make([]int, len(B)+1),
+		row:        make([]int, len(B)+1),
+		prevEntry:  make([]int, len(B)+1),
+		entry:      make([]int, len(B)+1),
+	}
+	h.align(A, B)
+
+	aligned := h.aligned
+	for i := range aligned.A {
+		if aligned.A[i] == '-' || aligned.B[i] == '-' {
+			aligned.Score += h.gapPenalty
+		} else {
+			aligned.Score += h.score(aligned.A[i], aligned.B[i])
+		}
+	}
+	aligned.AStart, aligned.AEnd = 0, len(A)
+	aligned.BStart, aligned.BEnd = 0, len(B)
+	return aligned
+}
+
+type hirschberg struct {
+	idx        AlphabetIndex
+	subst      SubstMatrix
+	gapPenalty int
+	aligned    Alignment
+
+	// Buffers for two rows of the score matrix and the corresponding entry
+	// columns (see split). These are reused at every level of recursion.
+	prev, row, prevEntry, entry []int
+}
+
+func (h *hirschberg) score(a, b Residue) int {
+	return h.subst.Scores[h.idx[a]][h.idx[b]]
+}
+
+// align appends an optimal alignment of A and B to h.aligned.
+func (h *hirschberg) align(A, B []Residue) {
+	switch {
+	case len(A) == 0:
+		for _, b := range B {
+			h.aligned.A = append(h.aligned.A, '-')
+			h.aligned.B = append(h.aligned.B, b)
+		}
+		return
+	case len(B) == 0:
+		for _, a := range A {
+			h.aligned.A = append(h.aligned.A, a)
+			h.aligned.B = append(h.aligned.B, '-')
+		}
+		return
+	case len(A) == 1 || len(B) == 1:
+		// The full dynamic programming table is linear in size here.
+		aligned := needlemanWunsch(A, B, h.subst, h.idx, h.gapPenalty)
+		h.aligned.A = append(h.aligned.A, aligned.A...)
+		h.aligned.B = append(h.aligned.B, aligned.B...)
+		return
+	}
+
+	// Split the problem at the cell in which the path found by
+	// NeedlemanWunsch reaches the middle row. Both halves of the path are
+	// then exactly the paths found by NeedlemanWunsch for the two halves of
+	// the problem.
+	mid := len(A) / 2
+	split := h.split(A, B, mid)
+	h.align(A[:mid], B[:split])
+	h.align(A[mid:], B[split:])
+}
+
+// split returns the column of the first cell in row mid that is visited by
+// the traceback of NeedlemanWunsch for A and B, using linear space.
+//
+// The score matrix is computed row by row. Below row mid, each cell also
+// records its entry column: the column at which the traceback from that cell
+// first reaches row mid. The traceback of NeedlemanWunsch prefers a diagonal
+// step, then a step up and then a step left, so the entry column of a cell is
+// the entry column of the first of its neighbors that the traceback would
+// step to.
+func (h *hirschberg) split(A, B []Residue, mid int) int {
+	prev, row := h.prev[:len(B)+1], h.row[:len(B)+1]
+	prevEntry, entry := h.prevEntry[:len(B)+1], h.entry[:len(B)+1]
+	for j := range prev {
+		prev[j] = h.gapPenalty * j
+	}
+	for i := 1; i <= len(A); i++ {
+		a := A[i-1]
+		row[0], entry[0] = h.gapPenalty*i, 0
+		for j := 1; j <= len(B); j++ {
+			diag := prev[j-1] + h.score(a, B[j-1])
+			up, left := prev[j]+h.gapPenalty, row[j-1]+h.gapPenalty
+			row[j] = max3(diag, up, left)
+			switch {
+			case i <= mid:
+			case row[j] == diag:
+				entry[j] = prevEntry[j-1]
+			case row[j] == up:
+				entry[j] = prevEntry[j]
+			default:
+				entry[j] = entry[j-1]
+			}
+		}
+		if i == mid {
+			for j := range entry {
+				entry[j] = j
+			}
+		}
+		prev, row = row, prev
+		prevEntry, entry = entry, prevEntry
+	}
+	return prevEntry[len(B)]
+}
@@ -0,0 +1,48 @@
+package seq
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestHirschberg(t *testing.T) {
+	for _, test := range alignTests {
+		s1, s2 := stringToSeq(test.seq1), stringToSeq(test.seq2)
+		testHirschbergSame(t, s1, s2, test.subst)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		s1 := randomResidues(rng, rng.Intn(50), "ACDEFGHIKLMNPQRSTVWY")
+		s2 := randomResidues(rng, rng.Intn(50), "ACDEFGHIKLMNPQRSTVWY")
+		testHirschbergSame(t, s1, s2, SubstBlosum62)
+	}
+
+	// An identity matrix over a small alphabet has many optimal alignments.
+	for i := 0; i < 500; i++ {
+		s1 := randomResidues(rng, rng.Intn(40), "ACGT")
+		s2 := randomResidues(rng, rng.Intn(40), "ACGT")
+		testHirschbergSame(t, s1, s2, SubstDNA)
+	}
+}
+
+func testHirschbergSame(t *testing.T, s1, s2 []Residue, subst SubstMatrix) {
+	nw := NeedlemanWunsch(s1, s2, subst)
+	h := Hirschberg(s1, s2, subst)
+	if nw.Score != h.Score {
+		t.Fatalf("Needleman-Wunsch score %d is not equal to Hirschberg "+
+			"score %d for\n%s\n%s", nw.Score, h.Score, s1, s2)
+	}
+	if string(nw.A) != string(h.A) || string(nw.B) != string(h.B) {
+		t.Fatalf("Needleman-Wunsch alignment\n%s\n%s\nis not equal to "+
+			"Hirschberg alignment\n%s\n%s", nw.A, nw.B, h.A, h.B)
+	}
+}
+
+func BenchmarkHirschberg(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		test := alignTests[0]
+		s1, s2 := stringToSeq(test.seq1), stringToSeq(test.seq2)
+		Hirschberg(s1, s2, test.subst)
+	}
+}
@@ -189,3 +189,133 @@ func stringToSeq(s string) []Residue {
 	}
 	return residues
 }
+
+func TestSmithWaterman(t *testing.T) {
+	tests := []struct {
+		seq1, seq2   string
+		out1, out2   string
+		subst        SubstMatrix
+		score        int
+		astart, aend int
+		bstart, bend int
+	}{
+		{
+			"GHIKLMNPQR", "AAAHIKLMNAA",
+			"HIKLMN", "HIKLMN",
+			SubstBlosum62, 32, 1, 7, 3, 9,
+		},
+		{
+			"GHIKLMNPQR", "AAAHIKWLMNAA",
+			"HIK-LMN", "HIKWLMN",
+			SubstBlosum62, 28, 1, 7, 3, 10,
+		},
+		{
+			"TTTACGTAAA", "GGACGTGG",
+			"ACGT", "ACGT",
+			SubstDNA, 8, 3, 7, 2, 6,
+		},
+		{
+			"AAAA", "WWWW",
+			"", "",
+			SubstBlosum62, 0, 0, 0, 0, 0,
+		},
+	}
+	for _, test := range tests {
+		s1, s2 := stringToSeq(test.seq1), stringToSeq(test.seq2)
+		aligned := SmithWaterman(s1, s2, test.subst)
+		sout1 := fmt.Sprintf("%s", aligned.A)
+		sout2 := fmt.Sprintf("%s", aligned.B)
+		if sout1 != test.out1 || sout2 != test.out2 {
+			t.Fatalf("Local alignment of\n%s\n%s\nresulted in\n%s\n%s\n"+
+				"but should have been\n%s\n%s",
+				test.seq1, test.seq2, sout1, sout2, test.out1, test.out2)
+		}
+		if aligned.Score != test.score {
+			t.Fatalf("Expected score %d but got %d.", test.score, aligned.Score)
+		}
+		if aligned.AStart != test.astart || aligned.AEnd != test.aend ||
+			aligned.BStart != test.bstart || aligned.BEnd != test.bend {
+			t.Fatalf("Expected region A[%d:%d], B[%d:%d] but got "+
+				"A[%d:%d], B[%d:%d].",
+				test.astart, test.aend, test.bstart, test.bend,
+				aligned.AStart, aligned.AEnd, aligned.BStart, aligned.BEnd)
+		}
+	}
+}
+
+func ExampleSmithWaterman() {
+	s1 := NewSequenceString("seq1", "GHIKLMNPQR")
+	s2 := NewSequenceString("seq2", "AAAHIKLMNAA")
+	aligned := SmithWaterman(s1.Residues, s2.Residues, SubstBlosum62)
+
+	fmt.Printf("%s\n", aligned.A)
+	fmt.Printf("%s\n", aligned.B)
+	fmt.Printf("score: %d, A[%d:%d], B[%d:%d]\n", aligned.Score,
+		aligned.AStart, aligned.AEnd, aligned.BStart, aligned.BEnd)
+	// Output:
+	// HIKLMN
+	// HIKLMN
+	// score: 32, A[1:7], B[3:9]
+}
+
+func TestAlignmentStats(t *testing.T) {
+	s1, s2 := stringToSeq("GHIKLMNPQR"), stringToSeq("GAAAHIKLMN")
+	aligned := NeedlemanWunsch(s1, s2, SubstBlosum62)
+	if aligned.Score != 14 {
+		t.Fatalf("Expected score 14 but got %d.", aligned.Score)
+	}
+	if aligned.AStart != 0 || aligned.AEnd != 10 ||
+		aligned.BStart != 0 || aligned.BEnd != 10 {
+		t.Fatalf("Expected global alignment to cover both sequences, but "+
+			"got A[%d:%d], B[%d:%d].",
+			aligned.AStart, aligned.AEnd, aligned.BStart, aligned.BEnd)
+	}
+
+	lower := Alignment{A: stringToSeq("ACgT"), B: stringToSeq("aCG-")}
+	tests := []struct {
+		name          string
+		computed, exp interface{}
+	}{
+		{"length", aligned.Len(), 13},
+		{"identities", aligned.Identities(), 7},
+		{"positives", aligned.Positives(SubstBlosum62), 7},
+		{"gaps", aligned.Gaps(), 6},
+		{"identity", aligned.Identity(), 7.0 / 13.0},
+		{"similarity", aligned.Similarity(SubstBlosum62), 7.0 / 13.0},
+		{"empty identity", Alignment{}.Identity(), 0.0},
+		{"lowercase identities", lower.Identities(), 3},
+		{"lowercase positives", lower.Positives(SubstBlosum62), 3},
+	}
+	for _, test := range tests {
+		if test.computed != test.exp {
+			t.Fatalf("Expected %s to be %v but got %v.",
+				test.name, test.exp, test.computed)
+		}
+	}
+	formatted := lower.Format("a", "b", SubstBlosum62, 60)
+	if !strings.Contains(formatted, "|||") {
+		t.Fatalf("Expected lowercase identities to be marked with '|' in\n%s",
+			formatted)
+	}
+}
+
+func ExampleAlignment_Format() {
+	s1 := NewSequenceString("seq1", "PPPPGHIKLMNPQRSTVWPPPP")
+	s2 := NewSequenceString("seq2", "CCGHIKLMNSTVWCC")
+	conf := NewAlignConfig(SubstBlosum62)
+	conf.Mode = AlignLocal
+	aligned := conf.Align(s1.Residues, s2.Residues)
+
+	fmt.Print(aligned.Format(s1.Name, s2.Name, SubstBlosum62, 10))
+	// Output:
+	// Score = 48
+	// Identities = 11/14 (78.6%), Positives = 11/14 (78.6%), Gaps = 3/14 (21.4%)
+	//
+	// seq1  5 GHIKLMNPQR 14
+	//         |||||||
+	// seq2  3 GHIKLMN--- 9
+	//
+	// seq1 15 STVW 18
+	//         ||||
+	// seq2 10 STVW 13
+}
@@ -0,0 +1,115 @@
+package seq
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Shuffle returns a random permutation of the residues given, using the
+// source of randomness given. The residues given are not modified.
+func Shuffle(rs []Residue, rng *rand.Rand) []Residue {
+	shuffled := make([]Residue, len(rs))
+	copy(shuffled, rs)
+	for i := len(shuffled) - 1; i > 0; i-- {
+		j := rng.Intn(i + 1)
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	}
+	return shuffled
+}
+
+// ShuffleKlet returns a random shuffle of the residues given that preserves
+// the exact number of occurrences of every k-let (subsequence of length k).
+// For example, with k = 2 this is the dinucleotide shuffle of Altschul and
+// Erickson (1985). The shuffle also preserves the first and last (k-1)-lets.
+// Every such shuffle is equally likely.
+//
+// The sequence is viewed as an Eulerian path through the graph whose
+// vertices are (k-1)-lets and whose edges are the k-lets of the sequence. A
+// random path is built by choosing a random spanning tree of last exits
+// (with Wilson's algorithm) and then randomly ordering the remaining exits
+// from each vertex.
+//
+// When k is 1, this is equivalent to Shuffle. ShuffleKlet panics if k is
+// less than 1. If k is at least the length of the sequence, then the only
+// shuffle is the sequence itself.
+func ShuffleKlet(rs []Residue, k int, rng *rand.Rand) []Residue {
+	if k < 1 {
+		panic(fmt.Sprintf("k-let shuffle requires k >= 1, but k = %d", k))
+	}
+	if k == 1 {
+		return Shuffle(rs, rng)
+	}
+	if k >= len(rs) {
+		shuffled := make([]Residue, len(rs))
+		copy(shuffled, rs)
+		return shuffled
+	}
+
+	// Build the graph. Vertices are numbered in order of first appearance,
+	// and the edges leaving each vertex are given by the index of the vertex
+	// they enter.
+	vertices := make(map[string]int)
+	vertex := func(i int) int {
+		key := string(Sequence{Residues: rs[i : i+k-1]}.Bytes())
+		v, ok := vertices[key]
+		if !ok {
+			v = len(vertices)
+			vertices[key] = v
+		}
+		return v
+	}
+	var path []int
+	for i := 0; i+k-1 <= len(rs); i++ {
+		path = append(path, vertex(i))
+	}
+	exits := make([][]int, len(vertices))
+	for i := 0; i+1 < len(path); i++ {
+		exits[path[i]] = append(exits[path[i]], path[i+1])
+	}
+	first, last := path[0], path[len(path)-1]
+
+	// Choose a random last exit from every vertex (other than the last
+	// vertex) such that the last exits form a tree rooted at the last vertex.
+	// Wilson's algorithm does this with loop erased random walks.
+	inTree := make([]bool, len(vertices))
+	lastExit := make([]int, len(vertices))
+	inTree[last] = true
+	for v := range exits {
+		for u := v; !inTree[u]; u = exits[u][lastExit[u]] {
+			lastExit[u] = rng.Intn(len(exits[u]))
+		}
+		for u := v; !inTree[u]; u = exits[u][lastExit[u]] {
+			inTree[u] = true
+		}
+	}
+
+	// Randomly order all other exits, and then take the last exit last.
+	// (The last vertex has no last exit, so all of its exits are shuffled.)
+	for v, vexits := range exits {
+		n := len(vexits)
+		if v != last {
+			n--
+			vexits[lastExit[v]], vexits[n] = vexits[n], vexits[lastExit[v]]
+		}
+		rng.Shuffle(n, func(i, j int) {
+			vexits[i], vexits[j] = vexits[j], vexits[i]
+		})
+	}
+
+	// Now walk the graph, spelling out the sequence as we go. The last
+	// residue of each vertex entered is the next residue in the sequence.
+	lastResidue := make([]Residue, len(vertices))
+	for i := 0; i+k-1 <= len(rs); i++ {
+		lastResidue[path[i]] = rs[i+k-2]
+	}
+	shuffled := make([]Residue, 0, len(rs))
+	shuffled = append(shuffled, rs[:k-1]...)
+	next := make([]int, len(vertices))
+	for v := first; next[v] < len(exits[v]); {
+		u := exits[v][next[v]]
+		next[v]++
+		shuffled = append(shuffled, lastResidue[u])
+		v = u
+	}
+	return shuffled
+}
@@ -0,0 +1,94 @@
+package seq
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func kletCounts(rs []Residue, k int) map[string]int {
+	counts := make(map[string]int)
+	for i := 0; i+k <= len(rs); i++ {
+		counts[resString(rs[i:i+k])]++
+	}
+	return counts
+}
+
+func TestShuffleKlet(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, k := range []int{1, 2, 3} {
+		for trial := 0; trial < 50; trial++ {
+			rs := randomResidues(rng, 1+rng.Intn(60), "ACGT")
+			orig := resString(rs)
+			shuffled := ShuffleKlet(rs, k, rng)
+			if resString(rs) != orig {
+				t.Fatalf("ShuffleKlet modified its input.")
+			}
+			if len(shuffled) != len(rs) {
+				t.Fatalf("Expected length %d but got %d.",
+					len(rs), len(shuffled))
+			}
+			want, got := kletCounts(rs, k), kletCounts(shuffled, k)
+			if len(want) != len(got) {
+				t.Fatalf("k = %d: %s and %s have different %d-lets.",
+					k, orig, resString(shuffled), k)
+			}
+			for klet, n := range want {
+				if got[klet] != n {
+					t.Fatalf("k = %d: %s has %d '%s' but %s has %d.",
+						k, orig, n, klet, resString(shuffled), got[klet])
+				}
+			}
+			if k > 1 && len(rs) >= k {
+				if resString(shuffled[:k-1]) != resString(rs[:k-1]) ||
+					resString(shuffled[len(rs)-k+1:]) !=
+						resString(rs[len(rs)-k+1:]) {
+					t.Fatalf("k = %d: %s and %s have different ends.",
+						k, orig, resString(shuffled))
+				}
+			}
+		}
+	}
+
+	// A shuffle should actually change things (most of the time).
+	rs := randomResidues(rng, 200, "ACGT")
+	if resString(ShuffleKlet(rs, 2, rng)) == resString(rs) {
+		t.Fatalf("Dinucleotide shuffle returned the original sequence.")
+	}
+}
+
+func TestFitGumbel(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	want := Gumbel{Mu: 25, Lambda: 0.3}
+	xs := make([]float64, 20000)
+	for i := range xs {
+		xs[i] = want.Mu - math.Log(-math.Log(rng.Float64()))/want.Lambda
+	}
+	got := FitGumbel(xs)
+	if math.Abs(got.Mu-want.Mu) > 0.1 ||
+		math.Abs(got.Lambda-want.Lambda) > 0.01 {
+		t.Fatalf("Expected %+v but got %+v.", want, got)
+	}
+	if p := got.PValue(got.Mu); math.Abs(p-(1-1/math.E)) > 1e-9 {
+		t.Fatalf("Expected a p-value of 1 - 1/e at mu but got %f.", p)
+	}
+}
+
+func TestShuffleSignificance(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const aminos = "ACDEFGHIKLMNPQRSTVWY"
+	A := randomResidues(rng, 100, aminos)
+	related := mutate(rng, A, 30, aminos)
+	unrelated := randomResidues(rng, 100, aminos)
+
+	conf := NewAlignConfig(SubstBlosum62)
+	conf.Mode = AlignLocal
+	sig := ShuffleSignificance(A, related, conf.Align, 200, 1, rng)
+	if sig.ZScore < 5 || sig.PValue > 1e-3 {
+		t.Fatalf("Expected a significant score, but got %+v.", sig)
+	}
+	sig = ShuffleSignificance(A, unrelated, conf.Align, 200, 2, rng)
+	if sig.ZScore > 4 || sig.PValue < 1e-3 {
+		t.Fatalf("Expected an insignificant score, but got %+v.", sig)
+	}
+}
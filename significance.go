@@ -0,0 +1,151 @@
+package seq
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// AlignFunc is any pairwise aligner, e.g., the method value of an
+// AlignConfig's Align method, or a closure around SmithWaterman with a
+// particular substitution matrix.
+type AlignFunc func(A, B []Residue) Alignment
+
+// Gumbel is an extreme value (type I) distribution with location Mu and
+// scale parameter Lambda. The scores of optimal local alignments of random
+// sequences are well described by a Gumbel distribution.
+type Gumbel struct {
+	Mu, Lambda float64
+}
+
+// FitGumbel computes the maximum likelihood fit of a Gumbel distribution to
+// the values given. At least two distinct values are required, otherwise
+// FitGumbel panics.
+func FitGumbel(xs []float64) Gumbel {
+	mean, sd := meanStdDev(xs)
+	if len(xs) < 2 || sd == 0 {
+		panic(fmt.Sprintf("Cannot fit a Gumbel distribution to %d values "+
+			"with standard deviation %f.", len(xs), sd))
+	}
+
+	// The maximum likelihood estimate of lambda is the root of
+	//
+	//	f(l) = 1/l - mean + sum(x*e^(-l*x)) / sum(e^(-l*x))
+	//
+	// which is strictly decreasing. Values are centered on the mean to avoid
+	// overflow.
+	f := func(lambda float64) float64 {
+		num, den := 0.0, 0.0
+		for _, x := range xs {
+			e := math.Exp(-lambda * (x - mean))
+			num += (x - mean) * e
+			den += e
+		}
+		return 1/lambda + num/den
+	}
+	guess := math.Pi / (sd * math.Sqrt(6)) // the method of moments estimate
+	left, right := guess, guess
+	for f(left) < 0 {
+		left /= 2
+	}
+	for f(right) > 0 {
+		right *= 2
+	}
+	for i := 0; i < 100 && right-left > 1e-12*right; i++ {
+		mid := (left + right) / 2
+		if f(mid) > 0 {
+			left = mid
+		} else {
+			right = mid
+		}
+	}
+	lambda := (left + right) / 2
+
+	sum := 0.0
+	for _, x := range xs {
+		sum += math.Exp(-lambda * (x - mean))
+	}
+	mu := mean - math.Log(sum/float64(len(xs)))/lambda
+	return Gumbel{Mu: mu, Lambda: lambda}
+}
+
+// PValue returns the probability of observing a value of at least x.
+func (g Gumbel) PValue(x float64) float64 {
+	return -math.Expm1(-math.Exp(-g.Lambda * (x - g.Mu)))
+}
+
+// Significance describes how an alignment score compares to the scores of
+// alignments with shuffled sequences.
+type Significance struct {
+	// The score of the alignment of the real sequences.
+	Score int
+
+	// The mean and standard deviation of the shuffled scores.
+	Mean, StdDev float64
+
+	// The number of standard deviations of the real score above the mean.
+	ZScore float64
+
+	// The extreme value distribution fit to the shuffled scores.
+	Fit Gumbel
+
+	// The probability of a score at least as high as the real score
+	// according to the fit.
+	PValue float64
+}
+
+// ShuffleSignificance estimates the significance of the alignment score of A
+// and B. B is shuffled n times (preserving k-let counts with ShuffleKlet, so
+// use k = 1 for a plain shuffle), and each shuffled sequence is aligned to A.
+// A Gumbel distribution is fit to the shuffled scores, which is then used to
+// compute the p-value of the real score.
+//
+// The fit is only meaningful for local alignment scores, and n should be at
+// least a few hundred for reliable p-values. If every shuffled score is the
+// same, then no fit is possible and the z-score and p-value are NaN.
+// ShuffleSignificance panics if n is less than 2.
+func ShuffleSignificance(
+	A, B []Residue,
+	align AlignFunc,
+	n, k int,
+	rng *rand.Rand,
+) Significance {
+	if n < 2 {
+		panic(fmt.Sprintf("At least 2 shuffles are required, but got %d.", n))
+	}
+	scores := make([]float64, n)
+	for i := range scores {
+		scores[i] = float64(align(A, ShuffleKlet(B, k, rng)).Score)
+	}
+
+	sig := Significance{Score: align(A, B).Score}
+	sig.Mean, sig.StdDev = meanStdDev(scores)
+	if sig.StdDev > 0 {
+		sig.ZScore = (float64(sig.Score) - sig.Mean) / sig.StdDev
+		sig.Fit = FitGumbel(scores)
+		sig.PValue = sig.Fit.PValue(float64(sig.Score))
+	} else {
+		sig.ZScore, sig.PValue = math.NaN(), math.NaN()
+	}
+	return sig
+}
+
+// meanStdDev returns the mean and (sample) standard deviation of the values.
+func meanStdDev(xs []float64) (float64, float64) {
+	if len(xs) == 0 {
+		return 0, 0
+	}
+	mean := 0.0
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	if len(xs) < 2 {
+		return mean, 0
+	}
+	ss := 0.0
+	for _, x := range xs {
+		ss += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(ss / float64(len(xs)-1))
+}
@@ -0,0 +1,240 @@
+package seq
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"text/tabwriter"
+)
+
+// Common units for the scores of a derived substitution matrix, given as
+// the number of score units per bit.
+const (
+	SubstBits      = 1
+	SubstHalfBits  = 2
+	SubstThirdBits = 3
+)
+
+// PairFrequencies represents (weighted) counts of aligned residue pairs. Like
+// a FrequencyProfile, it is useful as an intermediate representation. It can
+// be used to incrementally build a log-odds SubstMatrix from a collection of
+// alignments, in the same way that the BLOSUM matrices were built from the
+// BLOCKS database.
+type PairFrequencies struct {
+	// Counts[i][j] is the weighted number of times that residue i in the
+	// alphabet was aligned with residue j. Each aligned pair is counted in
+	// both orders, so the counts are always symmetric.
+	Counts [][]float64
+
+	// The alphabet of the counts, which also determines the alphabet of a
+	// substitution matrix derived from them. Gap characters in an alignment
+	// are never counted.
+	Alphabet Alphabet
+}
+
+// NewPairFrequencies initializes an empty set of pair counts with the given
+// alphabet.
+func NewPairFrequencies(alphabet Alphabet) *PairFrequencies {
+	counts := make([][]float64, len(alphabet))
+	for i := range counts {
+		counts[i] = make([]float64, len(alphabet))
+	}
+	return &PairFrequencies{counts, alphabet}
+}
+
+func (pf *PairFrequencies) String() string {
+	buf := new(bytes.Buffer)
+	tabw := tabwriter.NewWriter(buf, 4, 0, 3, ' ', 0)
+	pr := func(ft string, v ...interface{}) { fmt.Fprintf(tabw, ft, v...) }
+	for _, r := range pf.Alphabet {
+		pr("\t%c", rune(r))
+	}
+	pr("\n")
+	for i, r := range pf.Alphabet {
+		pr("%c", rune(r))
+		for _, count := range pf.Counts[i] {
+			pr("\t%0.2f", count)
+		}
+		pr("\n")
+	}
+	tabw.Flush()
+	return buf.String()
+}
+
+// AddAlignment counts every pair of aligned residues in a pairwise alignment.
+// Columns with a gap are ignored.
+//
+// As with FrequencyProfile.Add, residues that aren't in the alphabet are
+// counted as 'X' if the alphabet has an 'X'. Otherwise, AddAlignment panics.
+func (pf *PairFrequencies) AddAlignment(a Alignment) {
+	idx := pf.Alphabet.StrictIndex()
+	for i := range a.A {
+		pf.addPair(&idx, a.A[i], a.B[i], 1)
+	}
+}
+
+// AddMSA counts every pair of aligned residues in the match (and delete)
+// columns of a multiple sequence alignment. Insertion columns and pairs with
+// a gap are ignored.
+//
+// If identity is positive, then sequences are clustered as in the BLOSUM
+// procedure: any two sequences with at least that fraction of identical
+// residues (among the columns where neither has a gap) are put in the same
+// cluster. Pairs from sequences in the same cluster are not counted, and each
+// cluster contributes a total weight of one, so that a pair from clusters of
+// sizes n and m has weight 1 / (n*m). For example, an identity of 0.62
+// corresponds to the clustering used for BLOSUM62. If identity is not
+// positive, then sequences are not clustered and every pair has weight one.
+//
+// Residues that aren't in the alphabet are treated in the same way as
+// AddAlignment.
+func (pf *PairFrequencies) AddMSA(msa MSA, identity float64) {
+	var cols []int
+	for col := 0; col < msa.Len(); col++ {
+		if !msa.columnHasInsertion(col) {
+			cols = append(cols, col)
+		}
+	}
+	seqs := make([][]Residue, len(msa.Entries))
+	for i, s := range msa.Entries {
+		seqs[i] = make([]Residue, len(cols))
+		for j, col := range cols {
+			seqs[i][j] = s.Residues[col]
+		}
+	}
+
+	// Cluster with single linkage, where each cluster is identified by the
+	// index of one of its sequences.
+	cluster := make([]int, len(seqs))
+	for i := range cluster {
+		cluster[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if cluster[i] != i {
+			cluster[i] = find(cluster[i])
+		}
+		return cluster[i]
+	}
+	if identity > 0 {
+		for i := range seqs {
+			for j := i + 1; j < len(seqs); j++ {
+				if seqIdentity(seqs[i], seqs[j]) >= identity {
+					cluster[find(j)] = find(i)
+				}
+			}
+		}
+	}
+	sizes := make(map[int]int, len(seqs))
+	for i := range seqs {
+		sizes[find(i)]++
+	}
+
+	idx := pf.Alphabet.StrictIndex()
+	for i := range seqs {
+		for j := i + 1; j < len(seqs); j++ {
+			ci, cj := find(i), find(j)
+			if ci == cj {
+				continue
+			}
+			w := 1.0 / float64(sizes[ci]*sizes[cj])
+			for k := range seqs[i] {
+				pf.addPair(&idx, seqs[i][k], seqs[j][k], w)
+			}
+		}
+	}
+}
+
+// addPair adds the weight w to the count of the pair (a, b) in both orders.
+// The pair is ignored if either residue is a gap.
+func (pf *PairFrequencies) addPair(
+	idx *AlphabetIndex,
+	a, b Residue,
+	w float64,
+) {
+	if isGap(a) || isGap(b) {
+		return
+	}
+	i, j := pf.index(idx, a), pf.index(idx, b)
+	pf.Counts[i][j] += w
+	pf.Counts[j][i] += w
+}
+
+func (pf *PairFrequencies) index(idx *AlphabetIndex, r Residue) int {
+	if idx.Contains(r) {
+		return idx[r]
+	}
+	if idx.Contains('X') {
+		return idx['X']
+	}
+	panic(fmt.Sprintf("Unrecognized residue %c while using an "+
+		"alphabet without a wildcard: '%s'.", r, pf.Alphabet))
+}
+
+// SubstMatrix converts the pair counts to a log-odds substitution matrix.
+// The score of a pair (i, j) is log2(q_ij / (p_i * p_j)), where q_ij is the
+// observed frequency of the pair and p_i is the background frequency of
+// residue i among all counted pairs. Scores are multiplied by unitsPerBit
+// (e.g., SubstHalfBits) and rounded to the nearest integer.
+//
+// Pairs that were never observed (including every pair with a gap character,
+// if the alphabet has one) are given the lowest score of any observed pair.
+// (This mirrors the '*' row of the NCBI matrices.)
+func (pf *PairFrequencies) SubstMatrix(unitsPerBit float64) SubstMatrix {
+	total := 0.0
+	background := make([]float64, len(pf.Alphabet))
+	for i := range pf.Counts {
+		for _, count := range pf.Counts[i] {
+			background[i] += count
+			total += count
+		}
+	}
+
+	scores := make([][]int, len(pf.Alphabet))
+	lowest, observed := 0, false
+	for i := range scores {
+		scores[i] = make([]int, len(pf.Alphabet))
+		for j := range scores[i] {
+			if pf.Counts[i][j] == 0 {
+				continue
+			}
+			odds := (pf.Counts[i][j] / total) /
+				((background[i] / total) * (background[j] / total))
+			scores[i][j] = int(math.Floor(unitsPerBit*math.Log2(odds) + 0.5))
+			if !observed || scores[i][j] < lowest {
+				lowest, observed = scores[i][j], true
+			}
+		}
+	}
+	for i := range scores {
+		for j := range scores[i] {
+			if pf.Counts[i][j] == 0 {
+				scores[i][j] = lowest
+			}
+		}
+	}
+	return SubstMatrix{pf.Alphabet, scores}
+}
+
+// seqIdentity returns the fraction of identical residues between two aligned
+// sequences of the same length, among the columns where neither has a gap.
+func seqIdentity(a, b []Residue) float64 {
+	same, aligned := 0, 0
+	for i := range a {
+		if isGap(a[i]) || isGap(b[i]) {
+			continue
+		}
+		aligned++
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	if aligned == 0 {
+		return 0
+	}
+	return float64(same) / float64(aligned)
+}
+
+func isGap(r Residue) bool {
+	return r == '-' || r == '.'
+}
@@ -0,0 +1,85 @@
+package seq
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPairFrequenciesMSA(t *testing.T) {
+	alpha := NewAlphabet('A', 'C', 'G', 'T', '-')
+	msa := NewMSA()
+	msa.AddFastaSlice([]Sequence{
+		NewSequenceString("1", "ACGT"),
+		NewSequenceString("2", "ACGT"),
+		NewSequenceString("3", "AG-A"),
+	})
+
+	// Without clustering, every pair of sequences counts.
+	pf := NewPairFrequencies(alpha)
+	pf.AddMSA(msa, 0)
+	tests := []struct {
+		a, b  Residue
+		count float64
+	}{
+		{'A', 'A', 6}, {'C', 'C', 2}, {'G', 'G', 2}, {'T', 'T', 2},
+		{'C', 'G', 2}, {'A', 'T', 2}, {'T', 'A', 2}, {'G', '-', 0},
+	}
+	idx := alpha.Index()
+	check := func(pf *PairFrequencies) {
+		for _, test := range tests {
+			got := pf.Counts[idx[test.a]][idx[test.b]]
+			if math.Abs(got-test.count) > 1e-9 {
+				t.Fatalf("Expected count %f for (%c, %c) but got %f.",
+					test.count, test.a, test.b, got)
+			}
+		}
+	}
+	check(pf)
+
+	// Sequences 1 and 2 are identical, so they form a single cluster. Only
+	// the pairs with sequence 3 count, each with weight 1/2.
+	pf = NewPairFrequencies(alpha)
+	pf.AddMSA(msa, 0.62)
+	tests = []struct {
+		a, b  Residue
+		count float64
+	}{
+		{'A', 'A', 2}, {'C', 'C', 0}, {'G', 'G', 0}, {'T', 'T', 0},
+		{'C', 'G', 1}, {'A', 'T', 1}, {'T', 'A', 1},
+	}
+	check(pf)
+}
+
+func TestPairFrequenciesSubstMatrix(t *testing.T) {
+	alpha := NewAlphabet('A', 'C', 'X', '-')
+	pf := NewPairFrequencies(alpha)
+	pf.AddAlignment(Alignment{A: []Residue("AC-A"), B: []Residue("AAAN")})
+
+	// The ordered pair counts are (A,A) = 2, (A,C) = (C,A) = 1 and (A,X) =
+	// (X,A) = 1, since 'N' is counted as 'X'. So q(A,A) = 2/6, q(A,C) =
+	// q(A,X) = 1/6, p(A) = 4/6 and p(C) = p(X) = 1/6.
+	subst := pf.SubstMatrix(SubstHalfBits)
+	if err := subst.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	score := func(a, b float64) int {
+		return int(math.Floor(2*math.Log2(a/b) + 0.5))
+	}
+	aa := score(2.0/6.0, (4.0/6.0)*(4.0/6.0))
+	ac := score(1.0/6.0, (4.0/6.0)*(1.0/6.0))
+	// Unobserved pairs get the lowest observed score, which is aa.
+	answers := [][]int{
+		{aa, ac, ac, aa},
+		{ac, aa, aa, aa},
+		{ac, aa, aa, aa},
+		{aa, aa, aa, aa},
+	}
+	for i := range answers {
+		for j := range answers[i] {
+			if subst.Scores[i][j] != answers[i][j] {
+				t.Fatalf("Expected score %d for (%c, %c) but got %d.",
+					answers[i][j], alpha[i], alpha[j], subst.Scores[i][j])
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package seq
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadSubstMatrix reads a substitution matrix in the text format used by
+// NCBI BLAST and EMBOSS. Lines starting with '#' are comments. The first
+// non-comment line lists the residues of each column, and each subsequent
+// line starts with a residue followed by the scores in its row. The alphabet
+// of the matrix returned is in the order of the header line.
+//
+// Rows may be given in any order, but there must be exactly one row for each
+// column.
+//
+// Since NeedlemanWunsch and SmithWaterman read the gap penalty from the '-'
+// row of the matrix, a '-' row and column is added to matrices that have a
+// '*' row but no '-' row. Every score in it (including the score of aligning
+// '-' with '-') is the smallest score in the '*' row, which is the score of
+// aligning a residue with a stop codon in the NCBI matrices.
+func ReadSubstMatrix(r io.Reader) (SubstMatrix, error) {
+	lines := newLineReader(r)
+	var alphabet Alphabet
+	var scores [][]int
+	var seen []bool
+	var index [256]int
+	for {
+		line, err := lines.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return SubstMatrix{}, err
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if alphabet == nil {
+			for i := range index {
+				index[i] = -1
+			}
+			alphabet = make(Alphabet, len(fields))
+			for i, f := range fields {
+				if len(f) != 1 {
+					return SubstMatrix{}, lines.errorf("invalid residue '%s' "+
+						"in header", f)
+				}
+				if index[f[0]] >= 0 {
+					return SubstMatrix{}, lines.errorf("duplicate residue "+
+						"'%s' in header", f)
+				}
+				alphabet[i] = Residue(f[0])
+				index[f[0]] = i
+			}
+			scores = make([][]int, len(alphabet))
+			seen = make([]bool, len(alphabet))
+			continue
+		}
+
+		if len(fields[0]) != 1 || index[fields[0][0]] < 0 {
+			return SubstMatrix{}, lines.errorf("row residue '%s' is not in "+
+				"the header", fields[0])
+		}
+		row := index[fields[0][0]]
+		if seen[row] {
+			return SubstMatrix{}, lines.errorf("duplicate row for residue "+
+				"'%s'", fields[0])
+		}
+		if len(fields)-1 != len(alphabet) {
+			return SubstMatrix{}, lines.errorf("expected %d scores but got %d",
+				len(alphabet), len(fields)-1)
+		}
+		seen[row] = true
+		scores[row] = make([]int, len(alphabet))
+		for i, f := range fields[1:] {
+			if scores[row][i], err = strconv.Atoi(f); err != nil {
+				return SubstMatrix{}, lines.errorf("could not parse score "+
+					"'%s': %s", f, err)
+			}
+		}
+	}
+	if alphabet == nil {
+		return SubstMatrix{}, fmt.Errorf("no substitution matrix found")
+	}
+	for i, ok := range seen {
+		if !ok {
+			return SubstMatrix{}, fmt.Errorf("no row found for residue '%c'",
+				rune(alphabet[i]))
+		}
+	}
+
+	subst := SubstMatrix{alphabet, scores}
+	if star := index['*']; star >= 0 && index['-'] < 0 {
+		penalty := scores[star][0]
+		for _, score := range scores[star] {
+			penalty = min(penalty, score)
+		}
+		subst = subst.withGap(penalty)
+	}
+	return subst, nil
+}
+
+// withGap returns a copy of the substitution matrix with a '-' row and column
+// added to it, in which every score is the given gap penalty.
+func (m SubstMatrix) withGap(penalty int) SubstMatrix {
+	alphabet := make(Alphabet, len(m.Alphabet), len(m.Alphabet)+1)
+	copy(alphabet, m.Alphabet)
+	alphabet = append(alphabet, '-')
+
+	scores := make([][]int, len(alphabet))
+	for i := range scores {
+		scores[i] = make([]int, len(alphabet))
+		for j := range scores[i] {
+			if i < len(m.Scores) && j < len(m.Scores) {
+				scores[i][j] = m.Scores[i][j]
+			} else {
+				scores[i][j] = penalty
+			}
+		}
+	}
+	return SubstMatrix{alphabet, scores}
+}
+
+// WriteSubstMatrix writes a substitution matrix in the text format read by
+// ReadSubstMatrix.
+func WriteSubstMatrix(w io.Writer, subst SubstMatrix) error {
+	buf := bufio.NewWriter(w)
+	width := 2
+	for _, row := range subst.Scores {
+		for _, score := range row {
+			width = max(width, len(strconv.Itoa(score)))
+		}
+	}
+
+	fmt.Fprintf(buf, " ")
+	for _, r := range subst.Alphabet {
+		fmt.Fprintf(buf, " %*c", width, rune(r))
+	}
+	fmt.Fprintf(buf, "\n")
+	for i, r := range subst.Alphabet {
+		fmt.Fprintf(buf, "%c", rune(r))
+		for _, score := range subst.Scores[i] {
+			fmt.Fprintf(buf, " %*d", width, score)
+		}
+		fmt.Fprintf(buf, "\n")
+	}
+	return buf.Flush()
+}
+
+// mustReadSubstMatrix parses one of the built in substitution matrices and
+// panics if there's an error.
+func mustReadSubstMatrix(name, text string) SubstMatrix {
+	subst, err := ReadSubstMatrix(strings.NewReader(text))
+	if err != nil {
+		panic(fmt.Sprintf("BUG: could not read built in matrix %s: %s",
+			name, err))
+	}
+	return subst
+}
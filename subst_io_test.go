@@ -0,0 +1,165 @@
+package seq
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSubstMatrixRoundTrip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := WriteSubstMatrix(buf, SubstBlosum62); err != nil {
+		t.Fatal(err)
+	}
+	subst, err := ReadSubstMatrix(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subst.Alphabet.String() != SubstBlosum62.Alphabet.String() {
+		t.Fatalf("Expected alphabet '%s' but got '%s'.",
+			SubstBlosum62.Alphabet, subst.Alphabet)
+	}
+	for i := range subst.Scores {
+		for j := range subst.Scores[i] {
+			if subst.Scores[i][j] != SubstBlosum62.Scores[i][j] {
+				t.Fatalf("Expected score %d for (%c, %c) but got %d.",
+					SubstBlosum62.Scores[i][j], subst.Alphabet[i],
+					subst.Alphabet[j], subst.Scores[i][j])
+			}
+		}
+	}
+}
+
+func TestReadSubstMatrix(t *testing.T) {
+	input := "# a comment\n" +
+		"\n" +
+		"   A  C  *\n" +
+		"C -1  5 -3\n" +
+		"A  4 -1 -3\n" +
+		"* -3 -3  1\n"
+	subst, err := ReadSubstMatrix(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subst.Alphabet.String() != "AC*-" {
+		t.Fatalf("Expected alphabet 'AC*-' but got '%s'.", subst.Alphabet)
+	}
+	answer := [][]int{
+		{4, -1, -3, -3},
+		{-1, 5, -3, -3},
+		{-3, -3, 1, -3},
+		{-3, -3, -3, -3},
+	}
+	for i := range answer {
+		for j := range answer[i] {
+			if subst.Scores[i][j] != answer[i][j] {
+				t.Fatalf("Expected score %d at (%d, %d) but got %d.",
+					answer[i][j], i, j, subst.Scores[i][j])
+			}
+		}
+	}
+}
+
+func TestReadSubstMatrixErrors(t *testing.T) {
+	tests := []struct {
+		input, err string
+	}{
+		{"# nothing\n", "no substitution matrix"},
+		{"  A C\nA 1 0\n", "no row found"},
+		{"  A C\nA 1 0\nA 1 0\n", "line 3:"},
+		{"  A C\nG 1 0\n", "line 2:"},
+		{"  A C\nA 1\n", "line 2:"},
+		{"  A C\nA 1 x\n", "line 2:"},
+		{"  A AC\n", "line 1:"},
+		{"  A A\n", "line 1:"},
+	}
+	for _, test := range tests {
+		_, err := ReadSubstMatrix(strings.NewReader(test.input))
+		if err == nil {
+			t.Fatalf("Expected an error reading\n%s", test.input)
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("Expected error containing '%s' but got '%s'.",
+				test.err, err)
+		}
+	}
+}
+
+func TestBuiltinSubstMatrices(t *testing.T) {
+	tests := []struct {
+		name       string
+		a, b       Residue
+		score, gap int
+	}{
+		{"BLOSUM45", 'W', 'W', 15, -5},
+		{"BLOSUM50", 'C', 'C', 13, -5},
+		{"BLOSUM62", 'W', 'W', 11, -4},
+		{"BLOSUM80", 'W', 'Y', 2, -6},
+		{"BLOSUM90", 'P', 'P', 8, -6},
+		{"PAM30", 'W', 'E', -17, -17},
+		{"PAM70", 'M', 'M', 10, -11},
+		{"PAM250", 'F', 'Y', 7, -8},
+		{"NUC.4.4", 'A', 'R', 1, -4},
+	}
+	for _, test := range tests {
+		subst, ok := SubstMatrices[test.name]
+		if !ok {
+			t.Fatalf("No built in matrix named %s.", test.name)
+		}
+		idx := subst.Alphabet.Index()
+		for i := range subst.Scores {
+			if len(subst.Scores[i]) != len(subst.Alphabet) {
+				t.Fatalf("%s: row %d has %d scores but the alphabet has %d.",
+					test.name, i, len(subst.Scores[i]), len(subst.Alphabet))
+			}
+			for j := range subst.Scores[i] {
+				if subst.Scores[i][j] != subst.Scores[j][i] {
+					t.Fatalf("%s: scores for (%c, %c) are not symmetric.",
+						test.name, subst.Alphabet[i], subst.Alphabet[j])
+				}
+			}
+		}
+		if s := subst.Scores[idx[test.a]][idx[test.b]]; s != test.score {
+			t.Fatalf("%s: expected score %d for (%c, %c) but got %d.",
+				test.name, test.score, test.a, test.b, s)
+		}
+		if s := subst.Scores[idx['-']][idx['A']]; s != test.gap {
+			t.Fatalf("%s: expected gap penalty %d but got %d.",
+				test.name, test.gap, s)
+		}
+		if s := subst.Scores[idx['-']][idx['-']]; s != test.gap {
+			t.Fatalf("%s: expected gap penalty %d for ('-', '-') but got %d.",
+				test.name, test.gap, s)
+		}
+	}
+}
+
+func TestBuiltinGapPenalties(t *testing.T) {
+	// Gaps must be penalized, so only the gaps needed are introduced.
+	tests := []struct {
+		subst  SubstMatrix
+		a, b   string
+		alignA string
+		alignB string
+		score  int
+	}{
+		{
+			SubstBlosum45, "HEAGAWGHEE", "PAWHEAE",
+			"HEAGAWGHE-E", "-P--AW-HEAE", 17,
+		},
+		{SubstNuc44, "ACGTTACG", "ACGACG", "ACGTTACG", "ACG--ACG", 22},
+	}
+	for _, test := range tests {
+		aligned := NeedlemanWunsch(
+			[]Residue(test.a), []Residue(test.b), test.subst)
+		if string(aligned.A) != test.alignA ||
+			string(aligned.B) != test.alignB {
+			t.Fatalf("Expected alignment\n%s\n%s\nbut got\n%s\n%s",
+				test.alignA, test.alignB, aligned.A, aligned.B)
+		}
+		if aligned.Score != test.score {
+			t.Fatalf("Expected score %d but got %d.",
+				test.score, aligned.Score)
+		}
+	}
+}